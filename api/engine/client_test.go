@@ -0,0 +1,131 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func invoke(c runtime.ClientConn, name string, in *descriptorpb.FileDescriptorProto, opts ...runtime.CallOption) (*descriptorpb.FileDescriptorProto, error) {
+	for i := range fileDesc.Methods {
+		if fileDesc.Methods[i].Name == name {
+			out := new(descriptorpb.FileDescriptorProto)
+			err := c.Invoke(context.Background(), &fileDesc.Methods[i], in, out, opts...)
+			return out, err
+		}
+	}
+
+	return nil, errors.New("no method " + name)
+}
+
+func TestClient(t *testing.T) {
+	for _, en := range engines {
+		t.Run(en.name, func(t *testing.T) {
+			e := en.new()
+			a := runtime.NewApi(zap.NewNop(),
+				runtime.WithEngine(e),
+				runtime.WithUnaryInterceptor(func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+					r, _ := runtime.RequestFromContext(ctx)
+					if r.Header.Get("Authorization") == "" {
+						return nil, runtime.Unauthenticated("missing token")
+					}
+					if req.(*descriptorpb.FileDescriptorProto).GetName() == "missing.proto" {
+						return nil, runtime.Error(1001, "no such file")
+					}
+					return next(ctx, req)
+				}),
+			)
+			a.RegisterRouter(&fileDesc, nil)
+			s := httptest.NewServer(e)
+			defer s.Close()
+
+			c := runtime.NewClient(s.URL, runtime.WithCallOptions(runtime.WithHeader("Authorization", "Bearer t")))
+
+			// 路径变量和 query
+			out, err := invoke(c, "GetFile", &descriptorpb.FileDescriptorProto{
+				Name:       proto.String("bin v1.proto"),
+				Package:    proto.String("wms.v1"),
+				Dependency: []string{"a.proto", "b.proto"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, "GetFile", out.GetSyntax())
+			require.Equal(t, "bin v1.proto", out.GetName())
+			require.Equal(t, "wms.v1", out.GetPackage())
+			require.Equal(t, []string{"a.proto", "b.proto"}, out.GetDependency())
+
+			out, err = invoke(c, "UpdateFile", &descriptorpb.FileDescriptorProto{
+				Name:    proto.String("bin.proto"),
+				Package: proto.String("wms.v1"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, "UpdateFile", out.GetSyntax())
+			require.Equal(t, "wms.v1", out.GetPackage())
+
+			out, err = invoke(c, "DeleteFile", &descriptorpb.FileDescriptorProto{Name: proto.String("wms/v1/bin.proto")})
+			require.NoError(t, err)
+			require.Equal(t, "wms/v1/bin.proto", out.GetName())
+
+			// 业务错误码
+			_, err = invoke(c, "GetFile", &descriptorpb.FileDescriptorProto{Name: proto.String("missing.proto")})
+			require.True(t, errors.Is(err, runtime.Error(1001, "")))
+			require.Equal(t, "no such file", err.Error())
+
+			_, err = invoke(runtime.NewClient(s.URL), "GetFile", &descriptorpb.FileDescriptorProto{Name: proto.String("bin.proto")})
+			require.Equal(t, http.StatusUnauthorized, runtime.Convert(err).HTTPStatus())
+			require.Equal(t, runtime.CodeUnauthenticated, runtime.Convert(err).Code())
+
+			// 路径变量为空
+			_, err = invoke(c, "GetFile", &descriptorpb.FileDescriptorProto{})
+			require.Equal(t, runtime.CodeInvalidArgument, runtime.Convert(err).Code())
+		})
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"msg":"","data":{"name":"bin.proto"}}`))
+	}))
+	defer s.Close()
+
+	c := runtime.NewClient(s.URL)
+	in := &descriptorpb.FileDescriptorProto{Name: proto.String("bin.proto")}
+
+	_, err := invoke(c, "GetFile", in, runtime.WithRetry(1, time.Millisecond))
+	require.Equal(t, runtime.CodeUnavailable, runtime.Convert(err).Code())
+	require.Equal(t, "bad gateway", err.Error())
+
+	out, err := invoke(c, "GetFile", in, runtime.WithRetry(1, time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, "bin.proto", out.GetName())
+	require.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	_, err = invoke(c, "GetFile", in, runtime.WithTimeout(time.Nanosecond))
+	require.Equal(t, runtime.CodeDeadlineExceeded, runtime.Convert(err).Code())
+
+	// 业务错误码 14 不是 Unavailable, 不重试
+	atomic.StoreInt32(&calls, 0)
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"code":14,"msg":"bin locked","data":""}`))
+	}))
+	defer s.Close()
+
+	_, err = invoke(runtime.NewClient(s.URL), "GetFile", in, runtime.WithRetry(2, time.Millisecond))
+	require.True(t, errors.Is(err, runtime.Code(runtime.CodeUnavailable)))
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,304 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/devil-dwj/go-wms/api/middleware"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 两个 Engine 共用的一致性测试
+type testEngine interface {
+	runtime.Engine
+	http.Handler
+}
+
+var engines = []struct {
+	name string
+	new  func(opt ...engine.EngineOption) testEngine
+}{
+	{"gin", func(opt ...engine.EngineOption) testEngine { return engine.NewGinEngine(0, zap.NewNop(), opt...) }},
+	{"http", func(opt ...engine.EngineOption) testEngine { return engine.NewHttpEngine(0, zap.NewNop(), opt...) }},
+}
+
+// 回复请求本身, Syntax 为调用的方法名
+func fileHandler(name string) func(interface{}, context.Context, func(interface{}) error, runtime.UnaryInvoker) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, invoke runtime.UnaryInvoker) (interface{}, error) {
+		in := new(descriptorpb.FileDescriptorProto)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			req.(*descriptorpb.FileDescriptorProto).Syntax = &name
+			return req, nil
+		}
+		if invoke == nil {
+			return handler(ctx, in)
+		}
+		return invoke(ctx, in, handler)
+	}
+}
+
+var fileDesc = runtime.RouterDesc{
+	ServiceName: "wms.v1.FileService",
+	Methods: []runtime.MethodDesc{
+		{Name: "GetFile", Method: "GET", Path: "/v1/files/{name}", Handler: fileHandler("GetFile")},
+		{Name: "GetLatestFile", Method: "GET", Path: "/v1/files/latest", Handler: fileHandler("GetLatestFile")},
+		{Name: "CreateFile", Method: "POST", Path: "/v1/files", Body: "*", Handler: fileHandler("CreateFile")},
+		{Name: "UpdateFile", Method: "PUT", Path: "/v1/files/{name}", Body: "*", Handler: fileHandler("UpdateFile")},
+		{Name: "PatchFile", Method: "PATCH", Path: "/v1/files/{name}", Body: "*", Handler: fileHandler("PatchFile")},
+		{Name: "DeleteFile", Method: "DELETE", Path: "/v1/files/{name=**}", Handler: fileHandler("DeleteFile")},
+	},
+}
+
+type reply struct {
+	Code int32           `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+	// 成功时解码的 Data
+	File *descriptorpb.FileDescriptorProto
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string) (*httptest.ResponseRecorder, reply) {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var rep reply
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rep), w.Body.String())
+		if rep.Code == 0 {
+			require.NoError(t, json.Unmarshal(rep.Data, &rep.File))
+		}
+	}
+
+	return w, rep
+}
+
+func TestEngineConformance(t *testing.T) {
+	for _, en := range engines {
+		t.Run(en.name, func(t *testing.T) {
+			t.Run("routes", func(t *testing.T) {
+				e := en.new()
+				a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+				a.RegisterRouter(&fileDesc, nil)
+
+				w, rep := do(t, e, "GET", "/v1/files/bin.proto?package=wms.v1", "")
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, "GetFile", rep.File.GetSyntax())
+				require.Equal(t, "bin.proto", rep.File.GetName())
+				require.Equal(t, "wms.v1", rep.File.GetPackage())
+
+				// 字面量优先于变量
+				_, rep = do(t, e, "GET", "/v1/files/latest", "")
+				require.Equal(t, "GetLatestFile", rep.File.GetSyntax())
+
+				_, rep = do(t, e, "POST", "/v1/files", `{"name":"bin.proto","dependency":["a.proto"]}`)
+				require.Equal(t, "CreateFile", rep.File.GetSyntax())
+				require.Equal(t, []string{"a.proto"}, rep.File.GetDependency())
+
+				for _, m := range []string{"PUT", "PATCH"} {
+					_, rep = do(t, e, m, "/v1/files/bin.proto", `{"package":"wms.v1"}`)
+					require.Equal(t, "bin.proto", rep.File.GetName())
+					require.Equal(t, "wms.v1", rep.File.GetPackage())
+				}
+
+				_, rep = do(t, e, "DELETE", "/v1/files/wms/v1/bin.proto", "")
+				require.Equal(t, "DeleteFile", rep.File.GetSyntax())
+				require.Equal(t, "wms/v1/bin.proto", rep.File.GetName())
+
+				w, _ = do(t, e, "GET", "/v1/stocks", "")
+				require.Equal(t, http.StatusNotFound, w.Code)
+			})
+
+			t.Run("params", func(t *testing.T) {
+				// 同一位置的变量名不同, gin 按字段路径命名参数时注册会 panic
+				e := en.new()
+				a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+				require.NotPanics(t, func() {
+					a.RegisterRouter(&runtime.RouterDesc{
+						ServiceName: "wms.v1.PackageService",
+						Methods: []runtime.MethodDesc{
+							{Name: "GetPackage", Method: "GET", Path: "/v1/packages/{package}", Handler: fileHandler("GetPackage")},
+							{Name: "ListFiles", Method: "GET", Path: "/v1/packages/{name}/files", Handler: fileHandler("ListFiles")},
+							{Name: "GetWarehouse", Method: "GET", Path: "/v1/{name=warehouses/*}", Handler: fileHandler("GetWarehouse")},
+							{Name: "ListBins", Method: "GET", Path: "/v1/{package=warehouses/*}/bins", Handler: fileHandler("ListBins")},
+						},
+					}, nil)
+				})
+
+				_, rep := do(t, e, "GET", "/v1/packages/wms.v1", "")
+				require.Equal(t, "GetPackage", rep.File.GetSyntax())
+				require.Equal(t, "wms.v1", rep.File.GetPackage())
+
+				_, rep = do(t, e, "GET", "/v1/packages/bin.proto/files", "")
+				require.Equal(t, "ListFiles", rep.File.GetSyntax())
+				require.Equal(t, "bin.proto", rep.File.GetName())
+
+				_, rep = do(t, e, "GET", "/v1/warehouses/7", "")
+				require.Equal(t, "GetWarehouse", rep.File.GetSyntax())
+				require.Equal(t, "warehouses/7", rep.File.GetName())
+
+				_, rep = do(t, e, "GET", "/v1/warehouses/7/bins", "")
+				require.Equal(t, "ListBins", rep.File.GetSyntax())
+				require.Equal(t, "warehouses/7", rep.File.GetPackage())
+			})
+
+			t.Run("middleware", func(t *testing.T) {
+				var logged *middleware.MiddleWareRecord
+				e := en.new()
+				a := runtime.NewApi(zap.NewNop(),
+					runtime.WithEngine(e),
+					runtime.ChainMiddle(func(ctx context.Context, r *middleware.MiddleWareRecord) error {
+						if r.Request.Header.Get("Authorization") == "" {
+							return runtime.Unauthenticated("missing token")
+						}
+						return nil
+					}),
+					runtime.WithLog(func(ctx context.Context, r *middleware.MiddleWareRecord) error {
+						logged = r
+						return nil
+					}),
+				)
+				a.RegisterRouter(&fileDesc, nil)
+
+				w, rep := do(t, e, "GET", "/v1/files/bin.proto", "")
+				require.Equal(t, http.StatusUnauthorized, w.Code)
+				require.Equal(t, runtime.CodeUnauthenticated, rep.Code)
+
+				r := httptest.NewRequest("GET", "/v1/files/bin.proto", nil)
+				r.Header.Set("Authorization", "Bearer t")
+				w = httptest.NewRecorder()
+				e.ServeHTTP(w, r)
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, http.StatusOK, logged.Status)
+
+				w, _ = do(t, e, "OPTIONS", "/v1/files/bin.proto", "")
+				require.Equal(t, http.StatusNoContent, w.Code)
+				require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			})
+
+			t.Run("errors", func(t *testing.T) {
+				var logged *middleware.MiddleWareRecord
+				e := en.new(engine.WithMaxBodyBytes(16))
+				a := runtime.NewApi(zap.NewNop(),
+					runtime.WithEngine(e),
+					runtime.WithLog(func(ctx context.Context, r *middleware.MiddleWareRecord) error {
+						logged = r
+						return nil
+					}),
+				)
+				a.RegisterRouter(&fileDesc, nil)
+
+				w, rep := do(t, e, "POST", "/v1/files", `{"name":"0123456789.proto"}`)
+				require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+				require.Equal(t, runtime.CodeInvalidArgument, rep.Code)
+				require.Equal(t, http.StatusRequestEntityTooLarge, logged.Status)
+				require.Contains(t, logged.Err, "request body too large")
+
+				w, rep = do(t, e, "POST", "/v1/files", `{"name":`)
+				require.Equal(t, http.StatusBadRequest, w.Code)
+				require.Equal(t, runtime.CodeInvalidArgument, rep.Code)
+			})
+
+			t.Run("static", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bin.txt"), []byte("A-01"), 0644))
+
+				e := en.new()
+				runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithStatic(dir))
+
+				w, _ := do(t, e, "GET", dir+"/bin.txt", "")
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, "A-01", w.Body.String())
+
+				// 相对目录
+				wd, err := os.Getwd()
+				require.NoError(t, err)
+				require.NoError(t, os.Chdir(filepath.Dir(dir)))
+				defer func() { require.NoError(t, os.Chdir(wd)) }()
+
+				e = en.new()
+				runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithStatic("./"+filepath.Base(dir)+"/"))
+
+				w, _ = do(t, e, "GET", "/"+filepath.Base(dir)+"/bin.txt", "")
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, "A-01", w.Body.String())
+			})
+
+			t.Run("openapi", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.FileService.openapi.json"), []byte(`{"openapi":"3.0.3"}`), 0644))
+
+				e := en.new()
+				runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithOpenAPI(dir))
+
+				w, _ := do(t, e, "GET", "/swagger/", "")
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Contains(t, w.Body.String(), `{"url":"/swagger/file.FileService.openapi.json","name":"file.FileService"}`)
+
+				w = httptest.NewRecorder()
+				e.ServeHTTP(w, httptest.NewRequest("GET", "/swagger/file.FileService.openapi.json", nil))
+				require.Equal(t, http.StatusOK, w.Code)
+				require.JSONEq(t, `{"openapi":"3.0.3"}`, w.Body.String())
+
+				w, _ = do(t, e, "GET", "/swagger/../engine_test.go", "")
+				require.NotEqual(t, http.StatusOK, w.Code)
+			})
+
+			t.Run("handler", func(t *testing.T) {
+				e := en.new()
+				runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithHTTPHandler("/.well-known/jwks.json",
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						_, _ = w.Write([]byte(`{"keys":[]}`))
+					}),
+				))
+
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, `{"keys":[]}`, w.Body.String())
+			})
+
+			t.Run("lifecycle", func(t *testing.T) {
+				l, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+
+				e := en.new(engine.WithListener(l))
+				a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+				a.RegisterRouter(&fileDesc, nil)
+
+				done := make(chan error)
+				go func() { done <- e.Run() }()
+
+				resp, err := http.Get("http://" + l.Addr().String() + "/v1/files/bin.proto")
+				require.NoError(t, err)
+				resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+
+				require.NoError(t, a.Shutdown(context.Background()))
+				require.True(t, errors.Is(<-done, http.ErrServerClosed))
+			})
+		})
+	}
+}
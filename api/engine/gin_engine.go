@@ -1,11 +1,8 @@
 package engine
 
 import (
-	"fmt"
+	"context"
 	"net/http"
-	"reflect"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/devil-dwj/go-wms/api/middleware"
@@ -22,16 +19,21 @@ func init() {
 
 type GinEngine struct {
 	*gin.Engine
-	l       *zap.Logger
-	port    int
-	handler runtime.EngineHandler
+	l          *zap.Logger
+	port       int
+	handler    runtime.EngineHandler
+	stream     runtime.EngineStreamHandler
+	transcoder *runtime.Transcoder
+	server     *server
 }
 
-func NewGinEngine(port int, l *zap.Logger) *GinEngine {
+func NewGinEngine(port int, l *zap.Logger, opt ...GinEngineOption) *GinEngine {
 	e := &GinEngine{
-		Engine: gin.New(),
-		port:   port,
-		l:      l,
+		Engine:     gin.New(),
+		port:       port,
+		l:          l,
+		transcoder: runtime.NewTranscoder(nil, nil),
+		server:     newServer(port, l, opt...),
 	}
 
 	e.Engine.Use(Cors())
@@ -43,6 +45,14 @@ func (engine *GinEngine) Handler(handler runtime.EngineHandler) {
 	engine.handler = handler
 }
 
+func (engine *GinEngine) StreamHandler(handler runtime.EngineStreamHandler) {
+	engine.stream = handler
+}
+
+func (engine *GinEngine) Transcoder(t *runtime.Transcoder) {
+	engine.transcoder = t
+}
+
 func (engine *GinEngine) Log(handler runtime.MiddlewareFunc) {
 	engine.Engine.Use(func(ctx *gin.Context) {
 		r := &middleware.MiddleWareRecord{
@@ -57,8 +67,7 @@ func (engine *GinEngine) Log(handler runtime.MiddlewareFunc) {
 		r.Err = ctx.Errors.String()
 		r.Cost = time.Since(r.Start)
 
-		pctx := runtime.NewRequestContext(ctx.Request.Context(), ctx.Request)
-		_ = handler(pctx, r)
+		_ = handler(requestContext(ctx.Request), r)
 	})
 }
 
@@ -70,6 +79,16 @@ func (engine *GinEngine) Static(path string) {
 	engine.Engine.StaticFS(path, http.Dir(path))
 }
 
+func (engine *GinEngine) OpenAPI(path string) {
+	h := gin.WrapH(runtime.OpenAPIHandler(path))
+	engine.Engine.GET(runtime.OpenAPIPrefix+"*filepath", h)
+	engine.Engine.HEAD(runtime.OpenAPIPrefix+"*filepath", h)
+}
+
+func (engine *GinEngine) Handle(path string, h http.Handler) {
+	engine.Engine.Any(path, gin.WrapH(h))
+}
+
 func (engine *GinEngine) Use(handlers ...runtime.MiddlewareFunc) {
 	for _, handler := range handlers {
 		engine.Engine.Use(func(ctx *gin.Context) {
@@ -79,8 +98,7 @@ func (engine *GinEngine) Use(handlers ...runtime.MiddlewareFunc) {
 				Start:   time.Now(),
 			}
 
-			pctx := runtime.NewRequestContext(ctx.Request.Context(), ctx.Request)
-			err := handler(pctx, r)
+			err := handler(requestContext(ctx.Request), r)
 			if err != nil {
 				engine.fail(ctx, err)
 				ctx.Abort()
@@ -92,108 +110,69 @@ func (engine *GinEngine) Use(handlers ...runtime.MiddlewareFunc) {
 	}
 }
 
-func (engine *GinEngine) GET(path string) {
-	engine.Engine.GET(path, func(c *gin.Context) {
-		df := func(v interface{}) error {
-			refV := reflect.ValueOf(v).Elem()
-
-			for i := 0; i < refV.NumField(); i++ {
-				fieldInfo := refV.Type().Field(i)
-				tag := fieldInfo.Tag
-				name := tag.Get("json")
-				arr := strings.Split(name, ",")
-				if len(arr) < 1 {
-					continue
-				}
-				name = arr[0]
-				if name == "" {
-					continue
-				}
-
-				param := c.Query(name)
-				fieldType := fieldInfo.Type.Name()
-				if fieldType == "int32" {
-					paramInt, err := strconv.Atoi(param)
-					if err != nil {
-						return fmt.Errorf("query parma [%s]", name)
-					}
-					refV.FieldByName(fieldInfo.Name).Set(reflect.ValueOf(int32(paramInt)))
-				} else if fieldType == "int64" {
-					paramInt, err := strconv.Atoi(param)
-					if err != nil {
-						return fmt.Errorf("query parma [%s]", name)
-					}
-					refV.FieldByName(fieldInfo.Name).Set(reflect.ValueOf(int64(paramInt)))
-				} else {
-					refV.FieldByName(fieldInfo.Name).Set(reflect.ValueOf(param))
-				}
-			}
+func (engine *GinEngine) GET(route *runtime.Route) {
+	engine.Engine.GET(route.Template.Pattern(), engine.serve(route))
+}
 
-			return nil
-		}
+func (engine *GinEngine) POST(route *runtime.Route) {
+	engine.Engine.POST(route.Template.Pattern(), engine.serve(route))
+}
 
-		ctx := runtime.NewRequestContext(c.Request.Context(), c.Request)
-		reply, err := engine.handler(path, df, ctx)
-		if err != nil {
-			engine.fail(c, err)
-		} else {
-			engine.success(c, reply)
-		}
-	})
+func (engine *GinEngine) PUT(route *runtime.Route) {
+	engine.Engine.PUT(route.Template.Pattern(), engine.serve(route))
 }
 
-func (engine *GinEngine) POST(path string) {
-	engine.Engine.POST(path, func(c *gin.Context) {
-		df := func(v interface{}) error {
-			if err := c.ShouldBind(v); err != nil {
-				return err
-			}
+func (engine *GinEngine) PATCH(route *runtime.Route) {
+	engine.Engine.PATCH(route.Template.Pattern(), engine.serve(route))
+}
+
+func (engine *GinEngine) DELETE(route *runtime.Route) {
+	engine.Engine.DELETE(route.Template.Pattern(), engine.serve(route))
+}
 
-			return nil
+func (engine *GinEngine) serve(route *runtime.Route) gin.HandlerFunc {
+	if route.Stream {
+		return func(c *gin.Context) {
+			if err := engine.server.stream(c.Writer, c.Request, route, params(c), engine.stream, engine.transcoder); err != nil {
+				c.Error(err)
+			}
 		}
+	}
 
-		ctx := runtime.NewRequestContext(c.Request.Context(), c.Request)
-		reply, err := engine.handler(path, df, ctx)
-		if err != nil {
-			engine.fail(c, err)
-		} else {
-			engine.success(c, reply)
+	return func(c *gin.Context) {
+		if err := engine.server.handle(c.Writer, c.Request, route, params(c), engine.handler, engine.transcoder); err != nil {
+			c.Error(err)
 		}
-	})
+	}
+}
+
+func params(c *gin.Context) map[string]string {
+	p := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		p[param.Key] = param.Value
+	}
+
+	return p
 }
 
 func (engine *GinEngine) Run() error {
-	return engine.Engine.Run(fmt.Sprintf(":%d", engine.port))
+	return engine.server.run(engine.Engine)
+}
+
+func (engine *GinEngine) Shutdown(ctx context.Context) error {
+	return engine.server.shutdown(ctx)
 }
 
 func (engine *GinEngine) fail(c *gin.Context, err error) {
-	statusBadRequest := http.StatusBadRequest
-	var code int32 = 1
-	if e, ok := err.(interface {
-		Code() int32
-	}); ok {
-		code = e.Code()
-		statusBadRequest = http.StatusOK
-	}
 	c.Error(err)
-	c.JSON(
-		statusBadRequest,
-		gin.H{
-			"code": code,
-			"msg":  err.Error(),
-			"data": "",
-		})
+	engine.transcoder.WriteError(c.Writer, c.Request, err)
 }
 
-func (engine *GinEngine) success(c *gin.Context, data interface{}) {
-	c.JSON(
-		http.StatusOK,
-		gin.H{
-			"code": 0,
-			"msg":  "",
-			"data": data,
-		})
-}
+const (
+	corsAllowHeaders  = "Content-Type,AccessToken,X-CSRF-Token, Authorization"
+	corsAllowMethods  = "POST, GET, PUT, PATCH, DELETE, OPTIONS"
+	corsExposeHeaders = "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type"
+)
 
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -203,18 +182,18 @@ func Cors() gin.HandlerFunc {
 
 		if origin != "" {
 			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization") //自定义 Header
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders) //自定义 Header
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
 			c.Header("Access-Control-Allow-Credentials", "true")
 
 		}
 
 		if method == "OPTIONS" {
 			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization") //自定义 Header
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders) //自定义 Header
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
 			c.Header("Access-Control-Allow-Credentials", "true")
 			c.AbortWithStatus(http.StatusNoContent)
 		}
@@ -0,0 +1,55 @@
+package engine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 回复调用的 http 方法
+func methodHandler(method string) func(interface{}, context.Context, func(interface{}) error, runtime.UnaryInvoker) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, invoke runtime.UnaryInvoker) (interface{}, error) {
+		return &descriptorpb.FileDescriptorProto{Name: proto.String(method)}, nil
+	}
+}
+
+func TestGinEngineMethods(t *testing.T) {
+	e := engine.NewGinEngine(0, zap.NewNop())
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+
+	desc := runtime.RouterDesc{ServiceName: "wms.v1.BinService"}
+	methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	for _, m := range methods {
+		desc.Methods = append(desc.Methods, runtime.MethodDesc{Name: m + "Bin", Method: m, Path: "/v1/bins", Handler: methodHandler(m)})
+	}
+	a.RegisterRouter(&desc, nil)
+
+	// 同一路径按 http 方法分发
+	for _, m := range methods {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(m, "/v1/bins", nil))
+		require.Equal(t, http.StatusOK, w.Code, m)
+		require.Contains(t, w.Body.String(), `"name":"`+m+`"`, m)
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/v1/bins", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "POST, GET, PUT, PATCH, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+
+	// 不支持的 http 方法在启动时报错
+	a = runtime.NewApi(zap.NewNop(), runtime.WithEngine(engine.NewGinEngine(0, zap.NewNop())))
+	a.RegisterRouter(&runtime.RouterDesc{
+		ServiceName: "wms.v1.BinService",
+		Methods:     []runtime.MethodDesc{{Name: "HeadBin", Method: "HEAD", Path: "/v1/bins", Handler: methodHandler("HEAD")}},
+	}, nil)
+	require.EqualError(t, a.Run(), `router wms.v1.BinService.HeadBin: unsupported http method "HEAD"`)
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC 传输, 从 RouterDesc 生成 grpc.ServiceDesc, 与 Engine 共用服务实现和拦截器
+type GrpcServer struct {
+	server *grpc.Server
+	l      *zap.Logger
+	port   int
+	opts   engineOptions
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// 使用监听相关的选项 WithAddr, WithUnixSocket, WithListener, WithSystemd 和 WithGrpcServerOption
+func NewGrpcServer(port int, l *zap.Logger, opt ...EngineOption) *GrpcServer {
+	opts := engineOptions{}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	return &GrpcServer{
+		server: grpc.NewServer(opts.grpc...),
+		l:      l,
+		port:   port,
+		opts:   opts,
+	}
+}
+
+// 底层的 grpc.Server, 用于注册 health, reflection 等服务
+func (s *GrpcServer) Server() *grpc.Server {
+	return s.server
+}
+
+func (s *GrpcServer) RegisterService(service *runtime.Service) {
+	desc := &grpc.ServiceDesc{
+		ServiceName: service.Name,
+		// 服务实现已由 Api 按生成的接口注册, 这里不再检查
+		HandlerType: (*interface{})(nil),
+		Metadata:    service.Metadata,
+	}
+	for _, m := range service.Methods {
+		if m.Stream != nil {
+			desc.Streams = append(desc.Streams, grpc.StreamDesc{
+				StreamName:    m.Name,
+				Handler:       grpcStreamHandler(m),
+				ServerStreams: true,
+			})
+			continue
+		}
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: m.Name,
+			Handler:    grpcHandler(m),
+		})
+	}
+
+	s.server.RegisterService(desc, service.Impl)
+}
+
+func grpcHandler(m *runtime.ServiceMethod) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		// grpc.UnaryInterceptor 设置的拦截器, 在解码后执行
+		var i runtime.UnaryInterceptor
+		if interceptor != nil {
+			i = func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: info.FullMethod}, grpc.UnaryHandler(next))
+			}
+		}
+
+		reply, err := m.Call(requestContext(grpcRequest(ctx, m.FullMethod)), dec, i)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+
+		return reply, nil
+	}
+}
+
+// 服务端流, 请求只有一条, 由 RecvMsg 解码
+func grpcStreamHandler(m *runtime.ServiceMethod) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		ctx := requestContext(grpcRequest(stream.Context(), m.FullMethod))
+		if err := m.Stream(runtime.StreamWithContext(ctx, stream), stream.RecvMsg); err != nil {
+			return grpcError(err)
+		}
+
+		return nil
+	}
+}
+
+// 把调用转换为 *http.Request, 供 Api 的中间件, 日志和 token 等拦截器使用.
+// metadata 作为请求头, URL 为方法全名
+func grpcRequest(ctx context.Context, fullMethod string) *http.Request {
+	r := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: fullMethod},
+		RequestURI: fullMethod,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     make(http.Header),
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		if strings.HasPrefix(k, ":") {
+			continue
+		}
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	if authority := md.Get(":authority"); len(authority) > 0 {
+		r.Host = authority[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+
+	return r.WithContext(ctx)
+}
+
+// WarpError 转换为带 details 的 gRPC status.
+// 只有标准错误码直接作为 gRPC 状态码, 业务错误码转换为 Unknown, 原错误码放在 ErrorInfo 的 metadata 中
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	return status.ErrorProto(runtime.Convert(err).Proto())
+}
+
+func (s *GrpcServer) Run() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	l, err := s.opts.listen(s.port)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("grpc: %w", err)
+	}
+
+	return s.serve(l)
+}
+
+func (s *GrpcServer) serve(l net.Listener) error {
+	if err := s.server.Serve(l); err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+
+	return http.ErrServerClosed
+}
+
+// 等待处理中的调用完成, ctx 超时后强制关闭
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,160 @@
+package engine_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/devil-dwj/go-wms/api/middleware"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestGrpcServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var calls []string
+	e := engine.NewHttpEngine(0, zap.NewNop())
+	s := engine.NewGrpcServer(0, zap.NewNop(), engine.WithListener(l), engine.WithGrpcServerOption(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, "grpc "+info.FullMethod+" "+req.(*descriptorpb.FileDescriptorProto).GetName())
+			return handler(ctx, req)
+		}),
+	))
+	a := runtime.NewApi(zap.NewNop(),
+		runtime.WithEngine(e),
+		runtime.WithTransport(s),
+		runtime.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+			calls = append(calls, info.FullMethod)
+			if req.(*descriptorpb.FileDescriptorProto).GetName() == "missing.proto" {
+				return nil, runtime.NotFound("file not found").WithReason("FILE_NOT_FOUND")
+			}
+			return next(ctx, req)
+		}),
+	)
+	a.RegisterRouter(&fileDesc, nil)
+
+	done := make(chan error)
+	go func() { done <- s.Run() }()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	name := "bin.proto"
+	out := new(descriptorpb.FileDescriptorProto)
+	err = conn.Invoke(context.Background(), "/wms.v1.FileService/GetFile", &descriptorpb.FileDescriptorProto{Name: &name}, out)
+	require.NoError(t, err)
+	require.Equal(t, "bin.proto", out.GetName())
+	require.Equal(t, "GetFile", out.GetSyntax())
+
+	// 同一个拦截器链也用于 http, gRPC 的拦截器只用于 gRPC
+	w, _ := do(t, e, "GET", "/v1/files/bin.proto", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"grpc /wms.v1.FileService/GetFile bin.proto", "/wms.v1.FileService/GetFile", "/wms.v1.FileService/GetFile"}, calls)
+
+	missing := "missing.proto"
+	err = conn.Invoke(context.Background(), "/wms.v1.FileService/DeleteFile", &descriptorpb.FileDescriptorProto{Name: &missing}, out)
+	st := status.Convert(err)
+	require.Equal(t, codes.NotFound, st.Code())
+	require.Equal(t, "FILE_NOT_FOUND", st.Details()[0].(*errdetails.ErrorInfo).GetReason())
+
+	require.NoError(t, a.Shutdown(context.Background()))
+	require.ErrorIs(t, <-done, http.ErrServerClosed)
+}
+
+// gRPC 调用与 http 一样执行中间件, 日志和恢复
+func TestGrpcServerMiddleware(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		logged    []*middleware.MiddleWareRecord
+		recovered *middleware.MiddleWareRecord
+	)
+	s := engine.NewGrpcServer(0, zap.NewNop(), engine.WithListener(l))
+	a := runtime.NewApi(zap.NewNop(),
+		runtime.WithTransport(s),
+		runtime.ChainMiddle(func(ctx context.Context, r *middleware.MiddleWareRecord) error {
+			if r.Request.Header.Get("Authorization") == "" {
+				return runtime.Unauthenticated("missing token")
+			}
+			return nil
+		}),
+		runtime.WithLog(func(ctx context.Context, r *middleware.MiddleWareRecord) error {
+			logged = append(logged, r)
+			return nil
+		}),
+		runtime.WithRecovery(func(ctx context.Context, r *middleware.MiddleWareRecord) error {
+			recovered = r
+			return nil
+		}),
+		runtime.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+			switch req.(*descriptorpb.FileDescriptorProto).GetName() {
+			case "panic.proto":
+				panic("boom")
+			case "stock.proto":
+				return nil, runtime.Error(1001, "stock not enough")
+			case "locked.proto":
+				return nil, runtime.Error(runtime.CodeUnavailable, "bin locked")
+			}
+			return next(ctx, req)
+		}),
+	)
+	desc := fileDesc
+	desc.Metadata = "wms/v1/file.proto"
+	a.RegisterRouter(&desc, nil)
+	require.Equal(t, "wms/v1/file.proto", s.Server().GetServiceInfo()["wms.v1.FileService"].Metadata)
+
+	done := make(chan error)
+	go func() { done <- s.Run() }()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	get := func(ctx context.Context, name string) error {
+		return conn.Invoke(ctx, "/wms.v1.FileService/GetFile", &descriptorpb.FileDescriptorProto{Name: &name}, new(descriptorpb.FileDescriptorProto))
+	}
+
+	err = get(context.Background(), "bin.proto")
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Equal(t, http.StatusUnauthorized, logged[0].Status)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer t")
+	require.NoError(t, get(ctx, "bin.proto"))
+	require.Equal(t, http.StatusOK, logged[1].Status)
+	require.Equal(t, "/wms.v1.FileService/GetFile", logged[1].Request.URL.Path)
+
+	err = get(ctx, "panic.proto")
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Equal(t, "boom", recovered.Err)
+	require.Equal(t, "Bearer t", recovered.Request.Header.Get("Authorization"))
+
+	// 业务错误码转换为 Unknown, 原错误码在 ErrorInfo 中
+	err = get(ctx, "stock.proto")
+	st := status.Convert(err)
+	require.Equal(t, codes.Unknown, st.Code())
+	require.Equal(t, "stock not enough", st.Message())
+	require.Equal(t, map[string]string{"code": "1001"}, st.Details()[0].(*errdetails.ErrorInfo).GetMetadata())
+
+	// 与标准错误码同值的业务错误码也不作为 gRPC 状态码, 避免客户端按 Unavailable 重试
+	err = get(ctx, "locked.proto")
+	st = status.Convert(err)
+	require.Equal(t, codes.Unknown, st.Code())
+	require.Equal(t, map[string]string{"code": "14"}, st.Details()[0].(*errdetails.ErrorInfo).GetMetadata())
+	require.True(t, runtime.FromProto(st.Proto()).Legacy())
+
+	require.NoError(t, a.Shutdown(context.Background()))
+	require.ErrorIs(t, <-done, http.ErrServerClosed)
+}
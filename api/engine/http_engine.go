@@ -0,0 +1,297 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/middleware"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// 基于标准库的 Engine, 不依赖 gin
+type HttpEngine struct {
+	mux        *http.ServeMux
+	l          *zap.Logger
+	handler    runtime.EngineHandler
+	stream     runtime.EngineStreamHandler
+	transcoder *runtime.Transcoder
+	server     *server
+
+	routes map[string][]*runtime.Route
+	// 按注册顺序包装, 先注册的在外层
+	wrappers []func(http.Handler) http.Handler
+
+	once sync.Once
+	h    http.Handler
+}
+
+func NewHttpEngine(port int, l *zap.Logger, opt ...EngineOption) *HttpEngine {
+	e := &HttpEngine{
+		mux:        http.NewServeMux(),
+		l:          l,
+		transcoder: runtime.NewTranscoder(nil, nil),
+		server:     newServer(port, l, opt...),
+		routes:     make(map[string][]*runtime.Route),
+	}
+
+	e.mux.Handle("/", http.HandlerFunc(e.route))
+	e.wrappers = append(e.wrappers, corsHandler)
+
+	return e
+}
+
+func (engine *HttpEngine) Handler(handler runtime.EngineHandler) {
+	engine.handler = handler
+}
+
+func (engine *HttpEngine) StreamHandler(handler runtime.EngineStreamHandler) {
+	engine.stream = handler
+}
+
+func (engine *HttpEngine) Transcoder(t *runtime.Transcoder) {
+	engine.transcoder = t
+}
+
+func (engine *HttpEngine) Log(handler runtime.MiddlewareFunc) {
+	engine.wrappers = append(engine.wrappers, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &middleware.MiddleWareRecord{
+				Logger:  engine.l,
+				Request: r,
+				Start:   time.Now(),
+			}
+
+			errs := &requestErrors{}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestErrorsKey{}, errs)))
+
+			rec.Status = sw.status
+			rec.Err = errs.String()
+			rec.Cost = time.Since(rec.Start)
+
+			_ = handler(requestContext(r), rec)
+		})
+	})
+}
+
+func (engine *HttpEngine) Tracer(serverName string) {
+	engine.wrappers = append(engine.wrappers, func(next http.Handler) http.Handler {
+		return tracing(serverName, next)
+	})
+}
+
+// 与 gin 的 StaticFS 一致, URL 前缀为清理后的目录, 如 ./static 对应 /static
+func (engine *HttpEngine) Static(dir string) {
+	prefix := path.Join("/", dir)
+	engine.mux.Handle(strings.TrimSuffix(prefix, "/")+"/", http.StripPrefix(prefix, http.FileServer(http.Dir(dir))))
+}
+
+func (engine *HttpEngine) OpenAPI(path string) {
+	engine.mux.Handle(runtime.OpenAPIPrefix, runtime.OpenAPIHandler(path))
+}
+
+func (engine *HttpEngine) Handle(path string, h http.Handler) {
+	engine.mux.Handle(path, h)
+}
+
+func (engine *HttpEngine) Use(handlers ...runtime.MiddlewareFunc) {
+	for _, handler := range handlers {
+		handler := handler
+		engine.wrappers = append(engine.wrappers, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rec := &middleware.MiddleWareRecord{
+					Logger:  engine.l,
+					Request: r,
+					Start:   time.Now(),
+				}
+
+				if err := handler(requestContext(r), rec); err != nil {
+					addRequestError(r, err)
+					engine.transcoder.WriteError(w, r, err)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+func (engine *HttpEngine) GET(route *runtime.Route) {
+	engine.add(route)
+}
+
+func (engine *HttpEngine) POST(route *runtime.Route) {
+	engine.add(route)
+}
+
+func (engine *HttpEngine) PUT(route *runtime.Route) {
+	engine.add(route)
+}
+
+func (engine *HttpEngine) PATCH(route *runtime.Route) {
+	engine.add(route)
+}
+
+func (engine *HttpEngine) DELETE(route *runtime.Route) {
+	engine.add(route)
+}
+
+func (engine *HttpEngine) add(route *runtime.Route) {
+	engine.routes[route.Method] = append(engine.routes[route.Method], route)
+}
+
+// 选择匹配的路由, 多个匹配时字面量优先, 与 gin 一致
+func (engine *HttpEngine) route(w http.ResponseWriter, r *http.Request) {
+	var (
+		match  *runtime.Route
+		params map[string]string
+	)
+	for _, route := range engine.routes[r.Method] {
+		p, ok := route.Template.Match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if match == nil || moreSpecific(route.Template.Pattern(), match.Template.Pattern()) {
+			match, params = route, p
+		}
+	}
+
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+	trace.SpanFromContext(r.Context()).SetName(match.Path)
+
+	var err error
+	if match.Stream {
+		err = engine.server.stream(w, r, match, params, engine.stream, engine.transcoder)
+	} else {
+		err = engine.server.handle(w, r, match, params, engine.handler, engine.transcoder)
+	}
+	if err != nil {
+		addRequestError(r, err)
+	}
+}
+
+// 逐段比较, 字面量 > :param > *param
+func moreSpecific(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if ra, rb := segmentRank(as[i]), segmentRank(bs[i]); ra != rb {
+			return ra < rb
+		}
+	}
+
+	return len(as) > len(bs)
+}
+
+func segmentRank(s string) int {
+	switch {
+	case strings.HasPrefix(s, ":"):
+		return 1
+	case strings.HasPrefix(s, "*"):
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (engine *HttpEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	engine.once.Do(func() {
+		var h http.Handler = engine.mux
+		for i := len(engine.wrappers) - 1; i >= 0; i-- {
+			h = engine.wrappers[i](h)
+		}
+		engine.h = h
+	})
+
+	engine.h.ServeHTTP(w, r)
+}
+
+func (engine *HttpEngine) Run() error {
+	return engine.server.run(engine)
+}
+
+func (engine *HttpEngine) Shutdown(ctx context.Context) error {
+	return engine.server.shutdown(ctx)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// WebSocket 需要接管连接
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("engine: %T does not support hijacking", w.ResponseWriter)
+	}
+	w.status = http.StatusSwitchingProtocols
+
+	return h.Hijack()
+}
+
+// 请求处理中的错误, 由 Log 记录, 对应 gin 的 c.Errors
+type requestErrorsKey struct{}
+
+type requestErrors struct {
+	errs []error
+}
+
+func addRequestError(r *http.Request, err error) {
+	if errs, ok := r.Context().Value(requestErrorsKey{}).(*requestErrors); ok {
+		errs.errs = append(errs.errs, err)
+	}
+}
+
+func (e *requestErrors) String() string {
+	var b strings.Builder
+	for i, err := range e.errs {
+		fmt.Fprintf(&b, "Error #%02d: %s\n", i+1, err)
+	}
+
+	return b.String()
+}
+
+func corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" || r.Method == http.MethodOptions {
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
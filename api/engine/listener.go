@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// systemd 传入的第一个 fd
+const listenFdsStart = 3
+
+// 按 WithListener, WithSystemd, WithUnixSocket, WithAddr, port 的顺序选择
+func (o *engineOptions) listen(port int) (net.Listener, error) {
+	if o.listener != nil {
+		return o.listener, nil
+	}
+
+	if o.systemd {
+		l, err := systemdListener()
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			return l, nil
+		}
+	}
+
+	if o.unixSocket != "" {
+		if err := os.Remove(o.unixSocket); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", o.unixSocket)
+	}
+
+	addr := o.addr
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", port)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// sd_listen_fds(3), 不是由 systemd 启动时返回 nil
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+
+	// 不再传给子进程
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFdsStart), "LISTEN_FD_"+strconv.Itoa(listenFdsStart))
+	defer f.Close()
+
+	return net.FileListener(f)
+}
@@ -0,0 +1,130 @@
+package engine_test
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// 空闲的本地端口
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+// 等待 Engine 开始监听后请求
+func eventuallyPeer(t *testing.T, c *http.Client, url string) {
+	require.Eventually(t, func() bool {
+		_, err := getPeer(c, url)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestUnixSocket(t *testing.T) {
+	for _, en := range engines {
+		t.Run(en.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wms.sock")
+			// 上次运行残留的 socket 文件
+			require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+
+			// unix socket 优先于 WithAddr
+			addr := freeAddr(t)
+			run(t, en.new(engine.WithUnixSocket(path), engine.WithAddr(addr)))
+
+			eventuallyPeer(t, unixClient(path), "http://wms")
+			_, err := net.Dial("tcp", addr)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestListenPrecedence(t *testing.T) {
+	// WithAddr 优先于 port
+	addr := freeAddr(t)
+	_, port, err := net.SplitHostPort(freeAddr(t))
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	run(t, engine.NewGinEngine(p, zap.NewNop(), engine.WithAddr(addr)))
+	eventuallyPeer(t, http.DefaultClient, "http://"+addr)
+	_, err = net.Dial("tcp", "127.0.0.1:"+port)
+	require.Error(t, err)
+
+	// 不是由 systemd 启动时按其它选项监听
+	addr = freeAddr(t)
+	run(t, engine.NewGinEngine(0, zap.NewNop(), engine.WithSystemd(), engine.WithAddr(addr)))
+	eventuallyPeer(t, http.DefaultClient, "http://"+addr)
+
+	// WithListener 优先于其它选项
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "wms.sock")
+	run(t, engine.NewGinEngine(0, zap.NewNop(), engine.WithListener(l), engine.WithUnixSocket(path), engine.WithAddr(freeAddr(t))))
+	eventuallyPeer(t, http.DefaultClient, "http://"+l.Addr().String())
+	require.NoFileExists(t, path)
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	addr := serve(t, engine.WithReadHeaderTimeout(50*time.Millisecond))
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// 请求头没有发完, 超时后服务端关闭连接
+	_, err = conn.Write([]byte("GET /v1/peer HTTP/1.1\r\nHost: wms\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = bufio.NewReader(conn).ReadByte()
+	require.Error(t, err)
+	require.False(t, isTimeout(err), "server did not close the connection")
+}
+
+func TestIdleTimeout(t *testing.T) {
+	addr := serve(t, engine.WithIdleTimeout(50*time.Millisecond))
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /v1/peer HTTP/1.1\r\nHost: wms\r\n\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	_, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// keep-alive 连接空闲超时后被关闭
+	_, err = r.ReadByte()
+	require.Error(t, err)
+	require.False(t, isTimeout(err), "server did not close the connection")
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type engineOptions struct {
+	certFile   string
+	keyFile    string
+	clientCA   string
+	clientAuth *tls.ClientAuthType
+	h2c        bool
+
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	maxBodyBytes      int64
+	heartbeat         time.Duration
+	streamOrigins     []string
+
+	addr       string
+	unixSocket string
+	listener   net.Listener
+	systemd    bool
+
+	grpc []grpc.ServerOption
+}
+
+// GinEngine 和 HttpEngine 共用的选项
+type EngineOption interface {
+	apply(*engineOptions)
+}
+
+type GinEngineOption = EngineOption
+
+type funcEngineOption struct {
+	f func(*engineOptions)
+}
+
+func (feo *funcEngineOption) apply(o *engineOptions) {
+	feo.f(o)
+}
+
+func newFuncEngineOption(f func(*engineOptions)) *funcEngineOption {
+	return &funcEngineOption{f: f}
+}
+
+// 使用 HTTPS, 证书文件变化时自动重新加载
+func WithTLS(certFile, keyFile string) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	})
+}
+
+// 双向 TLS, 用 caFile 中的 CA 校验客户端证书, 需要同时设置 WithTLS
+func WithClientCA(caFile string) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.clientCA = caFile
+	})
+}
+
+// 客户端证书校验方式, 默认 tls.RequireAndVerifyClientCert, 与 WithClientCA 的顺序无关
+func WithClientAuth(auth tls.ClientAuthType) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.clientAuth = &auth
+	})
+}
+
+// 明文 HTTP/2 (h2c), 用于网格内部流量
+func WithH2C() EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.h2c = true
+	})
+}
+
+// 读取整个请求 (包括 body) 的超时
+func WithReadTimeout(d time.Duration) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.readTimeout = d
+	})
+}
+
+// 读取请求头的超时, 默认与 ReadTimeout 相同
+func WithReadHeaderTimeout(d time.Duration) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.readHeaderTimeout = d
+	})
+}
+
+// 写响应的超时, 流式响应开始后不再限制
+func WithWriteTimeout(d time.Duration) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.writeTimeout = d
+	})
+}
+
+// keep-alive 连接的空闲超时
+func WithIdleTimeout(d time.Duration) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.idleTimeout = d
+	})
+}
+
+// 请求头最大字节数, 默认 http.DefaultMaxHeaderBytes
+func WithMaxHeaderBytes(n int) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.maxHeaderBytes = n
+	})
+}
+
+// 请求体最大字节数, 超出返回 413, 默认不限制
+func WithMaxBodyBytes(n int64) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.maxBodyBytes = n
+	})
+}
+
+// 流式响应的心跳间隔, 默认 15s
+func WithHeartbeat(d time.Duration) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.heartbeat = d
+	})
+}
+
+// WebSocket 允许的 Origin, 如 https://wms.example.com, * 允许全部.
+// 默认只允许与请求 Host 相同的 Origin, 没有 Origin 的非浏览器客户端不受限制, 不允许时返回 403
+func WithStreamOrigins(origins ...string) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.streamOrigins = append(o.streamOrigins, origins...)
+	})
+}
+
+// 监听地址, 如 127.0.0.1:8080, 设置后忽略 port
+func WithAddr(addr string) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.addr = addr
+	})
+}
+
+// 监听 Unix socket, 启动时删除残留的 socket 文件
+func WithUnixSocket(path string) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.unixSocket = path
+	})
+}
+
+// 使用已打开的 listener
+func WithListener(l net.Listener) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.listener = l
+	})
+}
+
+// systemd socket activation, 使用 systemd 传入的第一个 socket, 没有则按其它选项监听
+func WithSystemd() EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.systemd = true
+	})
+}
+
+// GrpcServer 的 grpc.ServerOption, 如 grpc.Creds, grpc.MaxRecvMsgSize
+func WithGrpcServerOption(opts ...grpc.ServerOption) EngineOption {
+	return newFuncEngineOption(func(o *engineOptions) {
+		o.grpc = append(o.grpc, opts...)
+	})
+}
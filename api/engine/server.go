@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Engine 共用的 http.Server, 负责监听, TLS 和退出
+type server struct {
+	port int
+	l    *zap.Logger
+	opts engineOptions
+
+	mu       sync.Mutex
+	server   *http.Server
+	reloader *certReloader
+	closed   bool
+}
+
+// ConnContext 放入的连接, 流式响应用于取消写超时
+type connKey struct{}
+
+func newServer(port int, l *zap.Logger, opt ...EngineOption) *server {
+	opts := engineOptions{}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	return &server{port: port, l: l, opts: opts}
+}
+
+func (s *server) run(handler http.Handler) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	server, err := s.newServer(handler)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	l, err := s.opts.listen(s.port)
+	if err != nil {
+		if s.reloader != nil {
+			s.reloader.Close()
+		}
+		s.mu.Unlock()
+		return err
+	}
+	s.server = server
+	s.mu.Unlock()
+
+	if server.TLSConfig != nil {
+		return server.ServeTLS(l, "", "")
+	}
+
+	return server.Serve(l)
+}
+
+func (s *server) newServer(handler http.Handler) (*http.Server, error) {
+	if s.opts.h2c {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       s.opts.readTimeout,
+		ReadHeaderTimeout: s.opts.readHeaderTimeout,
+		WriteTimeout:      s.opts.writeTimeout,
+		IdleTimeout:       s.opts.idleTimeout,
+		MaxHeaderBytes:    s.opts.maxHeaderBytes,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+
+	if s.opts.certFile == "" {
+		if s.opts.clientCA != "" {
+			return nil, fmt.Errorf("engine: client CA requires TLS")
+		}
+		return server, nil
+	}
+
+	reloader, err := newCertReloader(s.opts.certFile, s.opts.keyFile, s.l)
+	if err != nil {
+		return nil, err
+	}
+	s.reloader = reloader
+
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if s.opts.clientCA != "" {
+		pool, err := loadCertPool(s.opts.clientCA)
+		if err != nil {
+			reloader.Close()
+			return nil, err
+		}
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if s.opts.clientAuth != nil {
+			server.TLSConfig.ClientAuth = *s.opts.clientAuth
+		}
+	}
+
+	return server, nil
+}
+
+func (s *server) shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	server := s.server
+	reloader := s.reloader
+	s.mu.Unlock()
+
+	if reloader != nil {
+		defer reloader.Close()
+	}
+
+	if server == nil {
+		return nil
+	}
+
+	return server.Shutdown(ctx)
+}
+
+// 处理路由请求: 限制请求体, 解码, 调用 handler 并写响应, 返回的错误已写入响应
+func (s *server) handle(w http.ResponseWriter, r *http.Request, route *runtime.Route, params map[string]string, handler runtime.EngineHandler, t *runtime.Transcoder) error {
+	if err := runtime.LimitRequestBody(r, s.opts.maxBodyBytes); err != nil {
+		t.WriteError(w, r, err)
+		return err
+	}
+
+	dec := func(v interface{}) error {
+		return t.Decode(r, route, params, v)
+	}
+
+	reply, err := handler(route.Method, route.Path, dec, requestContext(r))
+	if err != nil {
+		t.WriteError(w, r, err)
+		return err
+	}
+
+	t.WriteReply(w, r, route, reply)
+
+	return nil
+}
+
+func requestContext(r *http.Request) context.Context {
+	ctx := runtime.NewRequestContext(r.Context(), r)
+	// 只有校验过的客户端证书才放入 context
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		ctx = runtime.NewPeerContext(ctx, r.TLS.PeerCertificates[0])
+	}
+
+	return ctx
+}
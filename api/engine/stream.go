@@ -0,0 +1,315 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"golang.org/x/net/websocket"
+)
+
+const defaultHeartbeat = 15 * time.Second
+
+var errStreamClosed = errors.New("stream closed")
+
+// 处理服务端流路由: WebSocket 升级请求使用 WebSocket, 其它使用 SSE.
+// 消息使用默认编码, 不加信封, 流开始后的错误按信封编码作为最后一条消息
+func (s *server) stream(w http.ResponseWriter, r *http.Request, route *runtime.Route, params map[string]string, handler runtime.EngineStreamHandler, t *runtime.Transcoder) error {
+	if err := runtime.LimitRequestBody(r, s.opts.maxBodyBytes); err != nil {
+		t.WriteError(w, r, err)
+		return err
+	}
+
+	// Accept 为 text/event-stream, 消息固定使用默认编码
+	r.Header.Del("Accept")
+	dec := func(v interface{}) error {
+		return t.Decode(r, route, params, v)
+	}
+
+	if isWebSocket(r) {
+		return s.websocket(w, r, route, dec, handler, t)
+	}
+
+	clearWriteDeadline(w, r)
+	return s.sse(w, r, route, dec, handler, t)
+}
+
+// 流不受 WithWriteTimeout 限制, 断开由心跳检测.
+// Go 1.20 起 ResponseWriter 可以按请求设置, 否则 HTTP/1 直接设置连接
+func clearWriteDeadline(w http.ResponseWriter, r *http.Request) {
+	if d, ok := w.(interface{ SetWriteDeadline(time.Time) error }); ok && d.SetWriteDeadline(time.Time{}) == nil {
+		return
+	}
+	if c, ok := r.Context().Value(connKey{}).(net.Conn); ok && r.ProtoMajor == 1 {
+		_ = c.SetWriteDeadline(time.Time{})
+	}
+}
+
+// 没有 Origin 的非浏览器客户端, 同源请求和 WithStreamOrigins 允许的 Origin 可以升级
+func (s *server) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, o := range s.opts.streamOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return nil
+		}
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+
+	return fmt.Errorf("websocket: origin %q not allowed", origin)
+}
+
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// 定时调用 ping, 失败时取消流
+func (s *server) heartbeat(ctx context.Context, cancel context.CancelFunc, ping func() error) {
+	d := s.opts.heartbeat
+	if d <= 0 {
+		d = defaultHeartbeat
+	}
+
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (s *server) sse(w http.ResponseWriter, r *http.Request, route *runtime.Route, dec func(interface{}) error, handler runtime.EngineStreamHandler, t *runtime.Transcoder) error {
+	ctx, cancel := context.WithCancel(requestContext(r))
+	defer cancel()
+
+	st := &sseStream{ctx: ctx, w: w, m: t.Marshaler()}
+	go s.heartbeat(ctx, cancel, func() error {
+		return st.write([]byte(": ping\n\n"))
+	})
+
+	err := handler(route.Method, route.Path, dec, st)
+	cancel()
+	st.close(r, t, err)
+
+	return err
+}
+
+// text/event-stream, 每条消息为一个 data 事件, 注释行作为心跳.
+// 第一次写入时才发送响应头, 之前的错误按普通响应返回
+type sseStream struct {
+	ctx context.Context
+	w   http.ResponseWriter
+	m   runtime.Marshaler
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+}
+
+func (st *sseStream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *sseStream) SendMsg(m interface{}) error {
+	if err := st.ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := st.m.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return st.write(sseEvent("", data))
+}
+
+func (st *sseStream) write(p []byte) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.closed {
+		return errStreamClosed
+	}
+	st.start()
+
+	return st.flush(p)
+}
+
+func (st *sseStream) start() {
+	if st.started {
+		return
+	}
+	st.started = true
+
+	h := st.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	st.w.WriteHeader(http.StatusOK)
+}
+
+func (st *sseStream) flush(p []byte) error {
+	if _, err := st.w.Write(p); err != nil {
+		return err
+	}
+	if f, ok := st.w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+// 结束流, 未开始时按普通响应返回错误, 已开始则发送 error 事件
+func (st *sseStream) close(r *http.Request, t *runtime.Transcoder, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.closed = true
+	if err == nil {
+		return
+	}
+	if !st.started {
+		t.WriteError(st.w, r, err)
+		return
+	}
+	if data, merr := t.MarshalError(err); merr == nil {
+		_ = st.flush(sseEvent("error", data))
+	}
+}
+
+func sseEvent(name string, data []byte) []byte {
+	var b bytes.Buffer
+	if name != "" {
+		b.WriteString("event: " + name + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
+
+func (s *server) websocket(w http.ResponseWriter, r *http.Request, route *runtime.Route, dec func(interface{}) error, handler runtime.EngineStreamHandler, t *runtime.Transcoder) error {
+	var err error
+	websocket.Server{
+		// 浏览器不对 WebSocket 应用 cors, 在此检查 Origin
+		Handshake: func(_ *websocket.Config, r *http.Request) error { return s.checkOrigin(r) },
+		Handler: func(ws *websocket.Conn) {
+			err = s.serveWebSocket(ws, r, route, dec, handler, t)
+		},
+	}.ServeHTTP(w, r)
+
+	return err
+}
+
+func (s *server) serveWebSocket(ws *websocket.Conn, r *http.Request, route *runtime.Route, dec func(interface{}) error, handler runtime.EngineStreamHandler, t *runtime.Transcoder) error {
+	defer ws.Close()
+
+	// 接管的连接保留了 http.Server 设置的超时
+	_ = ws.SetDeadline(time.Time{})
+
+	// 连接已被接管, 客户端断开由读取失败得知
+	ctx, cancel := context.WithCancel(requestContext(r))
+	defer cancel()
+
+	st := &wsStream{
+		ctx:    ctx,
+		ws:     ws,
+		m:      t.Marshaler(),
+		binary: t.Marshaler().ContentType() == (runtime.ProtoMarshaler{}).ContentType(),
+	}
+
+	// 客户端发来的消息忽略, 只用于检测关闭
+	go func() {
+		defer cancel()
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+		}
+	}()
+	go s.heartbeat(ctx, cancel, st.ping)
+
+	err := handler(route.Method, route.Path, dec, st)
+	if err != nil && ctx.Err() == nil {
+		if data, merr := t.MarshalError(err); merr == nil {
+			_ = st.send(data)
+		}
+	}
+
+	return err
+}
+
+// 每条消息为一帧, protobuf 为二进制帧, 其它为文本帧
+type wsStream struct {
+	ctx    context.Context
+	ws     *websocket.Conn
+	m      runtime.Marshaler
+	binary bool
+
+	mu sync.Mutex
+}
+
+func (st *wsStream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *wsStream) SendMsg(m interface{}) error {
+	if err := st.ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := st.m.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return st.send(data)
+}
+
+func (st *wsStream) send(data []byte) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.binary {
+		return websocket.Message.Send(st.ws, data)
+	}
+
+	return websocket.Message.Send(st.ws, string(data))
+}
+
+// Conn.Write 按 PayloadType 发送, 临时改为 ping 帧
+func (st *wsStream) ping() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	payloadType := st.ws.PayloadType
+	st.ws.PayloadType = websocket.PingFrame
+	_, err := st.ws.Write(nil)
+	st.ws.PayloadType = payloadType
+
+	return err
+}
@@ -0,0 +1,236 @@
+package engine_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 按 name 发送两条消息: missing 开始前出错, broken 发送后出错, wait 发送一条后等待客户端断开
+func watchDesc(cancelled chan<- struct{}) *runtime.RouterDesc {
+	return &runtime.RouterDesc{
+		ServiceName: "wms.v1.FileService",
+		Methods: []runtime.MethodDesc{{
+			Name:   "WatchFile",
+			Method: "GET",
+			Path:   "/v1/files/{name}/watch",
+			StreamHandler: func(srv interface{}, stream runtime.ServerStream, dec func(interface{}) error, invoke runtime.UnaryInvoker) error {
+				in := new(descriptorpb.FileDescriptorProto)
+				if err := dec(in); err != nil {
+					return err
+				}
+
+				switch in.GetName() {
+				case "missing":
+					return runtime.NotFound("file %s not found", in.GetName())
+				case "wait":
+					if err := stream.SendMsg(in); err != nil {
+						return err
+					}
+					<-stream.Context().Done()
+					close(cancelled)
+					return stream.Context().Err()
+				}
+
+				for i := 0; i < 2; i++ {
+					if err := stream.SendMsg(in); err != nil {
+						return err
+					}
+				}
+				if in.GetName() == "broken" {
+					return runtime.Internal("file broken")
+				}
+				return nil
+			},
+		}},
+	}
+}
+
+func TestEngineStream(t *testing.T) {
+	for _, en := range engines {
+		t.Run(en.name, func(t *testing.T) {
+			cancelled := make(chan struct{})
+			e := en.new(engine.WithHeartbeat(10 * time.Millisecond))
+			a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+			a.RegisterRouter(watchDesc(cancelled), nil)
+
+			srv := httptest.NewServer(e)
+			defer srv.Close()
+
+			t.Run("sse", func(t *testing.T) {
+				resp, err := http.Get(srv.URL + "/v1/files/bin.proto/watch")
+				require.NoError(t, err)
+				body, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+				require.Equal(t, 2, strings.Count(string(body), `data: {"name":"bin.proto"}`), string(body))
+
+				// 开始前的错误按普通响应返回
+				resp, err = http.Get(srv.URL + "/v1/files/missing/watch")
+				require.NoError(t, err)
+				resp.Body.Close()
+				require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+				resp, err = http.Get(srv.URL + "/v1/files/broken/watch")
+				require.NoError(t, err)
+				body, _ = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.Contains(t, string(body), "event: error\ndata: {\"code\":")
+			})
+
+			t.Run("websocket", func(t *testing.T) {
+				ws, err := websocket.Dial(strings.Replace(srv.URL, "http", "ws", 1)+"/v1/files/broken/watch", "", srv.URL)
+				require.NoError(t, err)
+				defer ws.Close()
+
+				var msg string
+				for i := 0; i < 2; i++ {
+					require.NoError(t, websocket.Message.Receive(ws, &msg))
+					require.JSONEq(t, `{"name":"broken"}`, msg)
+				}
+
+				// 错误作为最后一条消息
+				require.NoError(t, websocket.Message.Receive(ws, &msg))
+				var rep reply
+				require.NoError(t, json.Unmarshal([]byte(msg), &rep))
+				require.Equal(t, runtime.CodeInternal, rep.Code)
+			})
+
+			t.Run("disconnect", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				r, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/v1/files/wait/watch", nil)
+				resp, err := http.DefaultClient.Do(r)
+				require.NoError(t, err)
+
+				br := bufio.NewReader(resp.Body)
+				line, err := br.ReadString('\n')
+				require.NoError(t, err)
+				require.Equal(t, "data: {\"name\":\"wait\"}\n", line)
+
+				// 心跳为注释行
+				for line != ": ping\n" {
+					line, err = br.ReadString('\n')
+					require.NoError(t, err)
+				}
+
+				cancel()
+				resp.Body.Close()
+				select {
+				case <-cancelled:
+				case <-time.After(time.Second):
+					t.Fatal("stream context not cancelled")
+				}
+			})
+		})
+	}
+}
+
+// 运行注册了 watchDesc 的 Engine, 返回地址
+func runStream(t *testing.T, en func(opt ...engine.EngineOption) testEngine, opt ...engine.EngineOption) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	e := en(append(opt, engine.WithListener(l), engine.WithHeartbeat(10*time.Millisecond))...)
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+	a.RegisterRouter(watchDesc(make(chan struct{})), nil)
+
+	done := make(chan error, 1)
+	go func() { done <- e.Run() }()
+	t.Cleanup(func() {
+		require.NoError(t, e.Shutdown(context.Background()))
+		<-done
+	})
+
+	return l.Addr().String()
+}
+
+// 写超时不中断流
+func TestStreamWriteTimeout(t *testing.T) {
+	for _, en := range engines {
+		t.Run(en.name, func(t *testing.T) {
+			addr := runStream(t, en.new, engine.WithWriteTimeout(50*time.Millisecond))
+			var resp *http.Response
+			require.Eventually(t, func() bool {
+				var err error
+				resp, err = http.Get("http://" + addr + "/v1/files/wait/watch")
+				return err == nil
+			}, 5*time.Second, 10*time.Millisecond)
+			defer resp.Body.Close()
+
+			br := bufio.NewReader(resp.Body)
+			deadline := time.Now().Add(200 * time.Millisecond)
+			for time.Now().Before(deadline) {
+				_, err := br.ReadString('\n')
+				require.NoError(t, err)
+			}
+
+			addr = runStream(t, en.new, engine.WithWriteTimeout(50*time.Millisecond))
+			var ws *websocket.Conn
+			require.Eventually(t, func() bool {
+				var err error
+				ws, err = websocket.Dial("ws://"+addr+"/v1/files/wait/watch", "", "http://"+addr)
+				return err == nil
+			}, 5*time.Second, 10*time.Millisecond)
+			defer ws.Close()
+
+			var msg string
+			require.NoError(t, websocket.Message.Receive(ws, &msg))
+			time.Sleep(200 * time.Millisecond)
+
+			// 连接仍然打开, 读取超时而不是被关闭
+			require.NoError(t, ws.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+			err := websocket.Message.Receive(ws, &msg)
+			require.True(t, isTimeout(err), "%v", err)
+		})
+	}
+}
+
+func TestStreamOrigin(t *testing.T) {
+	for _, en := range engines {
+		t.Run(en.name, func(t *testing.T) {
+			e := en.new(engine.WithStreamOrigins("https://wms.example.com"))
+			a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+			a.RegisterRouter(watchDesc(make(chan struct{})), nil)
+			srv := httptest.NewServer(e)
+			defer srv.Close()
+
+			url := strings.Replace(srv.URL, "http", "ws", 1) + "/v1/files/bin.proto/watch"
+			for _, origin := range []string{srv.URL, "https://wms.example.com"} {
+				ws, err := websocket.Dial(url, "", origin)
+				require.NoError(t, err, origin)
+				ws.Close()
+			}
+
+			// 其它站点的页面不能升级
+			_, err := websocket.Dial(url, "", "https://evil.example.com")
+			require.Error(t, err)
+
+			r, _ := http.NewRequest("GET", srv.URL+"/v1/files/bin.proto/watch", nil)
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set("Sec-WebSocket-Version", "13")
+			r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			r.Header.Set("Origin", "https://evil.example.com")
+			resp, err := http.DefaultClient.Do(r)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package engine_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// 测试用 CA, 签发服务端和客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "wms test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testCA{cert: cert, key: key, pool: pool, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// 签发证书, 返回 PEM 编码的证书和私钥
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) clientCert(t *testing.T, cn string) tls.Certificate {
+	certPEM, keyPEM := ca.issue(t, cn, 100, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	return cert
+}
+
+// 写入服务端证书, 返回证书和私钥文件
+func writeServerCert(t *testing.T, ca *testCA, dir string, serial int64) (string, string) {
+	certPEM, keyPEM := ca.issue(t, "127.0.0.1", serial, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(certFile, certPEM, 0644))
+
+	return certFile, keyFile
+}
+
+// 回复客户端证书的 CN, 没有证书时为空
+var peerDesc = runtime.RouterDesc{
+	ServiceName: "wms.v1.PeerService",
+	Methods: []runtime.MethodDesc{{
+		Name:   "GetPeer",
+		Method: "GET",
+		Path:   "/v1/peer",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, invoke runtime.UnaryInvoker) (interface{}, error) {
+			out := &descriptorpb.FileDescriptorProto{}
+			if cert, ok := runtime.PeerFromContext(ctx); ok {
+				out.Name = proto.String(cert.Subject.CommonName)
+			}
+			return out, nil
+		},
+	}},
+}
+
+// 在 127.0.0.1 上启动 Engine, 返回地址
+func serve(t *testing.T, opt ...engine.EngineOption) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	run(t, engine.NewGinEngine(0, zap.NewNop(), append(opt, engine.WithListener(l))...))
+
+	return l.Addr().String()
+}
+
+// 注册 peerDesc 后运行 Engine, 测试结束时退出
+func run(t *testing.T, e testEngine) {
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+	a.RegisterRouter(&peerDesc, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- e.Run() }()
+	t.Cleanup(func() {
+		require.NoError(t, e.Shutdown(context.Background()))
+		require.True(t, errors.Is(<-done, http.ErrServerClosed))
+	})
+}
+
+func getPeer(c *http.Client, url string) (string, error) {
+	resp, err := c.Get(url + "/v1/peer")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var rep reply
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		return "", err
+	}
+	var out descriptorpb.FileDescriptorProto
+	if err := json.Unmarshal(rep.Data, &out); err != nil {
+		return "", err
+	}
+
+	return out.GetName(), nil
+}
+
+func tlsClient(ca *testCA, certs ...tls.Certificate) *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      ca.pool,
+		Certificates: certs,
+	}}}
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir, 2)
+	addr := serve(t, engine.WithTLS(certFile, keyFile))
+
+	c := tlsClient(ca)
+	peer, err := getPeer(c, "https://"+addr)
+	require.NoError(t, err)
+	// 没有校验客户端证书时不放入 context
+	require.Equal(t, "", peer)
+
+	// 明文请求被拒绝
+	_, err = getPeer(http.DefaultClient, "http://"+addr)
+	require.Error(t, err)
+
+	// 替换证书文件后新连接使用新证书
+	writeServerCert(t, ca, dir, 3)
+	require.Eventually(t, func() bool {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: ca.pool})
+		if err != nil {
+			return false
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64() == 3
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeServerCert(t, ca, dir, 2)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(caFile, ca.pem, 0644))
+
+	// 默认要求客户端证书
+	addr := serve(t, engine.WithTLS(certFile, keyFile), engine.WithClientCA(caFile))
+
+	_, err := getPeer(tlsClient(ca), "https://"+addr)
+	require.Error(t, err)
+
+	peer, err := getPeer(tlsClient(ca, ca.clientCert(t, "handheld-01")), "https://"+addr)
+	require.NoError(t, err)
+	require.Equal(t, "handheld-01", peer)
+
+	// 其它 CA 签发的证书不能通过校验
+	_, err = getPeer(tlsClient(ca, newTestCA(t).clientCert(t, "intruder")), "https://"+addr)
+	require.Error(t, err)
+
+	// WithClientAuth 在 WithClientCA 之前设置也生效
+	addr = serve(t,
+		engine.WithTLS(certFile, keyFile),
+		engine.WithClientAuth(tls.VerifyClientCertIfGiven),
+		engine.WithClientCA(caFile),
+	)
+
+	peer, err = getPeer(tlsClient(ca), "https://"+addr)
+	require.NoError(t, err)
+	require.Equal(t, "", peer)
+
+	peer, err = getPeer(tlsClient(ca, ca.clientCert(t, "handheld-01")), "https://"+addr)
+	require.NoError(t, err)
+	require.Equal(t, "handheld-01", peer)
+}
+
+func TestClientCARequiresTLS(t *testing.T) {
+	e := engine.NewGinEngine(0, zap.NewNop(), engine.WithClientCA("ca.crt"))
+	require.EqualError(t, e.Run(), "engine: client CA requires TLS")
+}
+
+func TestH2C(t *testing.T) {
+	addr := serve(t, engine.WithH2C())
+
+	c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := c.Get("http://" + addr + "/v1/peer")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, resp.ProtoMajor)
+
+	// HTTP/1.1 仍然可用
+	resp, err = http.Get("http://" + addr + "/v1/peer")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, 1, resp.ProtoMajor)
+}
@@ -3,6 +3,9 @@ package runtime
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/devil-dwj/go-wms/api/middleware"
 	"github.com/opentracing/opentracing-go"
@@ -13,32 +16,60 @@ type methodHandler func(
 	srv interface{},
 	ctx context.Context,
 	dec func(interface{}) error,
+	invoke UnaryInvoker,
 ) (interface{}, error)
 
 type MethodDesc struct {
-	Name    string
-	Method  string
-	Path    string
-	Handler methodHandler
+	Name   string
+	Method string
+	Path   string
+	// HttpRule.body: "*" 整个请求, 字段名则只绑定该字段, 空则全部来自 query
+	Body string
+	// HttpRule.response_body: 只返回回复中的该字段
+	ResponseBody string
+	// 方法使用的拦截器名称, 来自 wms.api.method 选项, 由 WithInterceptor 注册
+	Middleware []string
+	// 允许匿名访问, 来自 wms.api.method 选项
+	Anonymous bool
+	// 需要的权限, 来自 wms.api.method 选项
+	Permission string
+	Handler    methodHandler
+	// 服务端流方法, 与 Handler 二选一
+	StreamHandler streamHandler
 }
 
-type routerInfo struct {
-	serviceName string
-	serveImpl   interface{}
-	methods     map[string]*MethodDesc
+type methodInfo struct {
+	serveImpl interface{}
+	desc      *MethodDesc
+	info      *MethodInfo
+	invoke    UnaryInvoker
+	route     RouteInfo
+	template  *PathTemplate
+}
+
+// 已注册的路由, 由 Api.Routes 返回
+type RouteInfo struct {
+	Service    string
+	Method     string
+	FullMethod string
+	HTTPMethod string
+	Path       string
 }
 
 // 注册路由
 type RouterRegistrar interface {
-	RegisterRouter(desc *RouterDesc, impl interface{})
+	RegisterRouter(desc *RouterDesc, impl interface{}, opts ...RouterOption)
 }
 
 type RouterDesc struct {
 	ServiceName string
 	Methods     []MethodDesc
+	// 定义服务的 proto 文件, 如 wms/v1/bin.proto
+	Metadata string
 }
 
 type EngineHandler func(
+	method string,
 	path string,
 	dec func(interface{}) error,
 	ctx context.Context,
@@ -49,7 +80,18 @@ type EngineHandler func(
 
 type MiddlewareFunc func(context.Context, *middleware.MiddleWareRecord) error
 
-type RestRegister func(string)
+// 路由, 注册时由 MethodDesc 生成, 交给 Engine 绑定
+type Route struct {
+	Method       string
+	Path         string
+	Body         string
+	ResponseBody string
+	Template     *PathTemplate
+	// 服务端流, Engine 以 SSE 或 WebSocket 提供
+	Stream bool
+}
+
+type RestRegister func(*Route)
 
 // 选择实现 log
 type Log interface {
@@ -65,18 +107,38 @@ type Tracing interface {
 type Engine interface {
 	Handler(EngineHandler)
 	Use(...MiddlewareFunc)
-	POST(path string)
-	GET(path string)
+	GET(*Route)
+	POST(*Route)
+	PUT(*Route)
+	PATCH(*Route)
+	DELETE(*Route)
 	Run() error
+	// 停止接收新请求并等待处理中的请求完成
+	Shutdown(context.Context) error
 }
 
 type Api struct {
 	opts         apiOptions
 	l            *zap.Logger
-	routers      map[string]*routerInfo
+	services     map[string]bool
+	methods      map[string]*methodInfo
+	patterns     map[string][]*methodInfo
+	routes       []RouteInfo
 	restHandlers map[string]RestRegister
+	// Use 和 ChainMiddle 注册的中间件, 传输调用时执行
+	middle []MiddlewareFunc
+	// Engine 是否支持流
+	streaming bool
+	err       error
+
+	hooks        []lifecycleHook
+	shutdownOnce sync.Once
+	shutdownErr  error
 }
 
+// 未设置 WithServiceName 时的服务名
+const DefaultServiceName = "user-service"
+
 func NewApi(l *zap.Logger, opt ...ApiOption) *Api {
 	opts := apiOptions{}
 	for _, o := range opt {
@@ -86,7 +148,9 @@ func NewApi(l *zap.Logger, opt ...ApiOption) *Api {
 	a := &Api{
 		l:            l,
 		opts:         opts,
-		routers:      make(map[string]*routerInfo),
+		services:     make(map[string]bool),
+		methods:      make(map[string]*methodInfo),
+		patterns:     make(map[string][]*methodInfo),
 		restHandlers: make(map[string]RestRegister),
 	}
 
@@ -107,8 +171,22 @@ func NewApi(l *zap.Logger, opt ...ApiOption) *Api {
 		if l, ok := opts.Engine.(interface {
 			Tracer(string)
 		}); ok {
-			l.Tracer("user-service")
+			service := opts.service
+			if service == "" {
+				service = DefaultServiceName
+			}
+			l.Tracer(service)
+		}
+	}
+
+	if t, ok := opts.Engine.(interface {
+		Transcoder(*Transcoder)
+	}); ok {
+		tc := NewTranscoder(opts.marshaler, opts.envelope)
+		for contentType, m := range opts.codecs {
+			tc.Register(contentType, m)
 		}
+		t.Transcoder(tc)
 	}
 
 	if opts.static != "" {
@@ -119,72 +197,354 @@ func NewApi(l *zap.Logger, opt ...ApiOption) *Api {
 		}
 	}
 
-	a.restRegist()
-	a.handler()
+	if opts.openapi != "" {
+		if l, ok := opts.Engine.(interface {
+			OpenAPI(path string)
+		}); ok {
+			l.OpenAPI(opts.openapi)
+		}
+	}
+
+	if len(opts.handlers) > 0 {
+		if l, ok := opts.Engine.(interface {
+			Handle(path string, h http.Handler)
+		}); ok {
+			for _, h := range opts.handlers {
+				l.Handle(h.path, h.h)
+			}
+		}
+	}
+
+	// 只使用 gRPC 等传输时可以没有 Engine
+	if opts.Engine != nil {
+		a.restRegist()
+		a.handler()
+	}
+
+	if s, ok := opts.Engine.(interface {
+		StreamHandler(EngineStreamHandler)
+	}); ok {
+		a.streaming = true
+		a.streamHandler(s)
+	}
 
 	return a
 }
 
-func (a *Api) RegisterRouter(rd *RouterDesc, srv interface{}) {
-	info := &routerInfo{
-		serviceName: rd.ServiceName,
-		serveImpl:   srv,
-		methods:     make(map[string]*MethodDesc),
+func (a *Api) RegisterRouter(rd *RouterDesc, srv interface{}, opt ...RouterOption) {
+	ro := routerOptions{}
+	for _, o := range opt {
+		o.apply(&ro)
 	}
 
+	if a.services[rd.ServiceName] {
+		a.setErr(fmt.Errorf("router %s: duplicate service", rd.ServiceName))
+		return
+	}
+	a.services[rd.ServiceName] = true
+
+	service := &Service{Name: rd.ServiceName, Impl: srv, Metadata: rd.Metadata}
 	for i := range rd.Methods {
 		d := &rd.Methods[i]
-		info.methods[d.Path] = d
-		if h, ok := a.restHandlers[d.Method]; ok {
-			h(d.Path)
+
+		interceptors, err := a.interceptors(rd.ServiceName, d, &ro)
+		if err != nil {
+			a.setErr(fmt.Errorf("router %s.%s: %w", rd.ServiceName, d.Name, err))
+			continue
 		}
+
+		info := &MethodInfo{
+			Server:     srv,
+			Service:    rd.ServiceName,
+			Name:       d.Name,
+			FullMethod: fullMethod(rd.ServiceName, d.Name),
+			HTTPMethod: d.Method,
+			Path:       d.Path,
+			Anonymous:  d.Anonymous,
+			Permission: d.Permission,
+		}
+		m := &methodInfo{
+			serveImpl: srv,
+			desc:      d,
+			info:      info,
+			invoke:    ChainUnaryInterceptors(interceptors...).bind(info),
+			route: RouteInfo{
+				Service:    rd.ServiceName,
+				Method:     d.Name,
+				FullMethod: fullMethod(rd.ServiceName, d.Name),
+				HTTPMethod: d.Method,
+				Path:       d.Path,
+			},
+		}
+
+		sm := &ServiceMethod{Name: d.Name, FullMethod: m.route.FullMethod}
+		if d.StreamHandler != nil {
+			sm.Stream = func(stream ServerStream, dec func(interface{}) error) error {
+				return a.serveTransport(stream.Context(), func() error {
+					return a.callStream(m, stream, dec)
+				})
+			}
+		} else {
+			sm.Call = func(ctx context.Context, dec func(interface{}) error, interceptor UnaryInterceptor) (reply interface{}, err error) {
+				err = a.serveTransport(ctx, func() error {
+					reply, err = a.call(m, ctx, dec, interceptor)
+					return err
+				})
+				return reply, err
+			}
+		}
+		service.Methods = append(service.Methods, sm)
+
+		// 没有 http 规则的方法只通过其它传输提供
+		if a.opts.Engine == nil || d.Method == "" && len(a.opts.transports) > 0 {
+			continue
+		}
+		a.registerRoute(m)
 	}
 
-	a.routers[rd.ServiceName] = info
+	for _, t := range a.opts.transports {
+		t.RegisterService(service)
+	}
+}
+
+func (a *Api) registerRoute(m *methodInfo) {
+	d, service := m.desc, m.route.Service
+	h, ok := a.restHandlers[d.Method]
+	if !ok {
+		// 未注册的 http 方法, 启动时报错, 不静默丢弃
+		if d.Method == "" {
+			a.setErr(fmt.Errorf("router %s.%s: missing google.api.http rule", service, d.Name))
+		} else {
+			a.setErr(fmt.Errorf("router %s.%s: unsupported http method %q", service, d.Name, d.Method))
+		}
+		return
+	}
+
+	if d.StreamHandler != nil && !a.streaming {
+		a.setErr(fmt.Errorf("router %s.%s: engine does not support streaming", service, d.Name))
+		return
+	}
+
+	t, err := ParsePathTemplate(d.Path)
+	if err != nil {
+		a.setErr(fmt.Errorf("router %s.%s: %w", service, d.Name, err))
+		return
+	}
+
+	// 变量名不同但形状相同的路径也会冲突, 如 /v1/bins/{id} 与 /v1/bins/{bin_id}.
+	// 在注册到 Engine 之前检查, 避免 gin 注册时 panic
+	for _, c := range a.patterns[d.Method] {
+		if t.conflicts(c.template) {
+			a.setErr(fmt.Errorf("router %s.%s: %s %s conflicts with %s", service, d.Name, d.Method, d.Path, c.route.FullMethod))
+			return
+		}
+	}
+
+	m.template = t
+	a.methods[methodKey(d.Method, d.Path)] = m
+	a.patterns[d.Method] = append(a.patterns[d.Method], m)
+	a.routes = append(a.routes, m.route)
+
+	h(&Route{
+		Method:       d.Method,
+		Path:         d.Path,
+		Body:         d.Body,
+		ResponseBody: d.ResponseBody,
+		Template:     t,
+		Stream:       d.StreamHandler != nil,
+	})
+}
+
+// 已注册的路由, 按注册顺序
+func (a *Api) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(a.routes))
+	copy(routes, a.routes)
+
+	return routes
 }
 
 func (a *Api) Use(middle ...MiddlewareFunc) {
-	a.opts.Engine.Use(middle...)
+	a.middle = append(a.middle, middle...)
+	if a.opts.Engine != nil {
+		a.opts.Engine.Use(middle...)
+	}
 }
 
+// 阻塞运行, 收到 SIGINT/SIGTERM 后优雅退出
 func (a *Api) Run() error {
-	fmt.Println("start api server")
-
-	return a.opts.Engine.Run()
+	return a.Start(context.Background())
 }
 
 func (a *Api) restRegist() {
-	a.restHandlers["GET"] = a.opts.GET
+	a.restHandlers["GET"] = a.opts.Engine.GET
 	a.restHandlers["POST"] = a.opts.Engine.POST
+	a.restHandlers["PUT"] = a.opts.Engine.PUT
+	a.restHandlers["PATCH"] = a.opts.Engine.PATCH
+	a.restHandlers["DELETE"] = a.opts.Engine.DELETE
+}
+
+// 记录第一个注册错误, 由 Run 返回
+func (a *Api) setErr(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// 方法的拦截器: 全局, 服务级别, 选项中命名的, 再到方法级别
+func (a *Api) interceptors(service string, d *MethodDesc, ro *routerOptions) ([]UnaryInterceptor, error) {
+	var interceptors []UnaryInterceptor
+	interceptors = append(interceptors, a.opts.unaryInterceptors...)
+	interceptors = append(interceptors, ro.interceptors...)
+
+	for _, name := range d.Middleware {
+		i, ok := a.opts.interceptors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown interceptor %q", name)
+		}
+		interceptors = append(interceptors, i)
+	}
+
+	interceptors = append(interceptors, ro.methods[d.Name]...)
+	interceptors = append(interceptors, ro.methods[fullMethod(service, d.Name)]...)
+
+	return interceptors, nil
+}
+
+func methodKey(method, path string) string {
+	return method + " " + path
 }
 
 func (a *Api) handler() {
-	a.opts.Engine.Handler(func(path string, dec func(interface{}) error, ctx context.Context) (interface{}, error) {
-		for _, info := range a.routers {
-			if md, ok := info.methods[path]; ok {
-				if a.opts.recovery != nil {
-					defer func() {
-						if err := recover(); err != nil {
-							if r, b := RequestFromContext(ctx); b {
-								a.opts.recovery(ctx, &middleware.MiddleWareRecord{
-									Logger:  a.l,
-									Request: r,
-									Err:     err,
-								})
-							}
-						}
-					}()
-				}
-
-				passCtx := NewRedisContext(ctx, a.opts.r)
-				return md.Handler(
-					info.serveImpl,
-					passCtx,
-					dec,
-				)
-			}
+	a.opts.Engine.Handler(func(method string, path string, dec func(interface{}) error, ctx context.Context) (interface{}, error) {
+		key := methodKey(method, path)
+		m, ok := a.methods[key]
+		if !ok {
+			return nil, NotFound("not find register method: %s", key)
 		}
 
-		return nil, fmt.Errorf("not find register method: %s", path)
+		return a.call(m, ctx, dec, nil)
 	})
 }
+
+func (a *Api) streamHandler(s interface {
+	StreamHandler(EngineStreamHandler)
+}) {
+	s.StreamHandler(func(method string, path string, dec func(interface{}) error, stream ServerStream) error {
+		key := methodKey(method, path)
+		m, ok := a.methods[key]
+		if !ok || m.desc.StreamHandler == nil {
+			return NotFound("not find register stream method: %s", key)
+		}
+
+		return a.callStream(m, stream, dec)
+	})
+}
+
+// 调用方法, Engine 和其它传输共用
+func (a *Api) call(m *methodInfo, ctx context.Context, dec func(interface{}) error, interceptor UnaryInterceptor) (reply interface{}, err error) {
+	if a.opts.recovery != nil {
+		defer a.recover(ctx, m, &err)
+	}
+
+	invoke := m.invoke
+	if interceptor != nil {
+		invoke = m.invokeWith(interceptor)
+	}
+
+	return m.desc.Handler(
+		m.serveImpl,
+		a.context(ctx),
+		dec,
+		invoke,
+	)
+}
+
+// 在方法的拦截器链之外执行 i
+func (m *methodInfo) invokeWith(i UnaryInterceptor) UnaryInvoker {
+	return func(ctx context.Context, req interface{}, handler UnaryHandler) (interface{}, error) {
+		return i(ctx, req, m.info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			if m.invoke == nil {
+				return handler(ctx, req)
+			}
+			return m.invoke(ctx, req, handler)
+		})
+	}
+}
+
+// 调用服务端流方法, 拦截器在整个流期间执行, 回复为 nil
+func (a *Api) callStream(m *methodInfo, stream ServerStream, dec func(interface{}) error) (err error) {
+	if a.opts.recovery != nil {
+		defer a.recover(stream.Context(), m, &err)
+	}
+
+	return m.desc.StreamHandler(
+		m.serveImpl,
+		StreamWithContext(a.context(stream.Context()), stream),
+		dec,
+		m.invoke,
+	)
+}
+
+func (a *Api) context(ctx context.Context) context.Context {
+	ctx = NewRedisContext(ctx, a.opts.r)
+	if a.opts.validator != nil {
+		ctx = NewValidatorContext(ctx, a.opts.validator)
+	}
+
+	return ctx
+}
+
+// 传输调用时与 Engine 一样执行中间件和日志, 中间件返回错误时不调用方法.
+// 中间件依赖请求, 传输须用 NewRequestContext 放入 ctx, 没有时拒绝调用
+func (a *Api) serveTransport(ctx context.Context, call func() error) error {
+	req, ok := RequestFromContext(ctx)
+	if !ok {
+		if len(a.middle) > 0 || a.opts.log != nil {
+			return Internal("transport: missing request for middleware")
+		}
+		return call()
+	}
+
+	r := &middleware.MiddleWareRecord{
+		Logger:  a.l,
+		Request: req,
+		Start:   time.Now(),
+	}
+
+	var err error
+	for _, m := range a.middle {
+		if err = m(ctx, r); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = call()
+	}
+
+	if a.opts.log != nil {
+		r.Status = Convert(err).HTTPStatus()
+		if err != nil {
+			r.Err = err.Error()
+		}
+		r.Cost = time.Since(r.Start)
+		_ = a.opts.log(ctx, r)
+	}
+
+	return err
+}
+
+// 方法 panic 时记录并返回 Internal
+func (a *Api) recover(ctx context.Context, m *methodInfo, err *error) {
+	if r := recover(); r != nil {
+		if req, b := RequestFromContext(ctx); b {
+			a.opts.recovery(ctx, &middleware.MiddleWareRecord{
+				Logger:  a.l,
+				Request: req,
+				Err:     r,
+			})
+		} else {
+			a.l.Error("[Recovery from panic]"+m.route.FullMethod, zap.Any("error", r))
+		}
+		*err = Internal("%s: panic", m.route.FullMethod)
+	}
+}
@@ -0,0 +1,114 @@
+package runtime_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/middleware"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRoutes(t *testing.T) {
+	e := &fakeEngine{}
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithInterceptor("audit", audit))
+	a.RegisterRouter(&binDesc, binServer{})
+	require.NoError(t, a.Run())
+
+	require.Equal(t, []runtime.RouteInfo{{
+		Service:    "wms.v1.BinService",
+		Method:     "GetBin",
+		FullMethod: "/wms.v1.BinService/GetBin",
+		HTTPMethod: "GET",
+		Path:       "/v1/bins/{bin_id}",
+	}}, a.Routes())
+	require.Len(t, e.routes, 1)
+
+	_, err := e.handler("GET", "/v1/stocks", nil, context.Background())
+	require.Equal(t, runtime.CodeNotFound, runtime.Convert(err).Code())
+}
+
+func TestDuplicateRoutes(t *testing.T) {
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(&fakeEngine{}), runtime.WithInterceptor("audit", audit))
+	a.RegisterRouter(&binDesc, binServer{})
+	a.RegisterRouter(&binDesc, binServer{})
+	require.EqualError(t, a.Run(), "router wms.v1.BinService: duplicate service")
+
+	e := &fakeEngine{}
+	a = runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithInterceptor("audit", audit))
+	a.RegisterRouter(&binDesc, binServer{})
+	a.RegisterRouter(&runtime.RouterDesc{
+		ServiceName: "wms.v1.StockService",
+		Methods: []runtime.MethodDesc{{
+			Name:   "GetStock",
+			Method: "GET",
+			Path:   "/v1/bins/{id}",
+		}},
+	}, nil)
+	require.EqualError(t, a.Run(), "router wms.v1.StockService.GetStock: GET /v1/bins/{id} conflicts with /wms.v1.BinService/GetBin")
+	require.Len(t, e.routes, 1)
+}
+
+// gin 不能注册的组合在注册时记录为错误, 不在 Engine 中 panic
+func TestConflictingRoutes(t *testing.T) {
+	cases := []struct {
+		paths    []string
+		conflict bool
+	}{
+		{[]string{"/v1/bins/{id}", "/v1/bins/{bin_id}"}, true},
+		{[]string{"/v1/{name=warehouses/*}", "/v1/{parent=warehouses/*}"}, true},
+		{[]string{"/v1/files/{name=**}", "/v1/files/latest"}, true},
+		{[]string{"/v1/files/latest", "/v1/files/{name=**}"}, true},
+		{[]string{"/v1/files/{name}", "/v1/files/{path=**}"}, true},
+		{[]string{"/v1/files/{name}/items", "/v1/{name=files/**}"}, true},
+		{[]string{"/v1/bins/{id}", "/v1/bins/{bin_id}/items", "/v1/bins/latest"}, false},
+		{[]string{"/v1/{name=warehouses/*}", "/v1/{parent=warehouses/*}/bins"}, false},
+		{[]string{"/v1/files", "/v1/files/{name=**}"}, false},
+		{[]string{"/v1/stocks/{name=**}", "/v1/files/{name=**}"}, false},
+	}
+	for _, c := range cases {
+		desc := runtime.RouterDesc{ServiceName: "wms.v1.FileService"}
+		for i, p := range c.paths {
+			desc.Methods = append(desc.Methods, runtime.MethodDesc{Name: fmt.Sprint("Method", i), Method: "GET", Path: p})
+		}
+
+		e := &fakeEngine{}
+		a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e))
+		a.RegisterRouter(&desc, nil)
+		if c.conflict {
+			require.Error(t, a.Run(), "%v", c.paths)
+			require.Len(t, e.routes, len(c.paths)-1, "%v", c.paths)
+		} else {
+			require.NoError(t, a.Run(), "%v", c.paths)
+			require.Len(t, e.routes, len(c.paths), "%v", c.paths)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	e := &fakeEngine{}
+	a := runtime.NewApi(zap.NewNop(),
+		runtime.WithEngine(e),
+		runtime.WithRecovery(func(context.Context, *middleware.MiddleWareRecord) error { return nil }),
+		runtime.WithInterceptor("audit", func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+			panic("boom")
+		}),
+	)
+	a.RegisterRouter(&binDesc, binServer{})
+
+	reply, err := e.handler("GET", "/v1/bins/{bin_id}", nil, context.Background())
+	require.Nil(t, reply)
+	require.Equal(t, runtime.CodeInternal, runtime.Convert(err).Code())
+}
+
+func TestTracingServiceName(t *testing.T) {
+	e := &fakeEngine{}
+	runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithTracing(true), runtime.WithServiceName("stock-service"))
+	require.Equal(t, "stock-service", e.tracer)
+
+	e = &fakeEngine{}
+	runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithTracing(true))
+	require.Equal(t, runtime.DefaultServiceName, e.tracer)
+}
@@ -0,0 +1,323 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 生成的 XxxRouterClient 通过 ClientConn 调用方法, 测试时可替换
+type ClientConn interface {
+	Invoke(ctx context.Context, desc *MethodDesc, in, out proto.Message, opts ...CallOption) error
+}
+
+// 按 HttpRule 调用 Engine 提供的 http 接口, 实现 ClientConn.
+// 编码和信封须与服务端一致, 默认 JSONBuiltin 和 LegacyEnvelope
+type Client struct {
+	endpoint string
+	opts     clientOptions
+	// *MethodDesc -> *Route
+	routes sync.Map
+}
+
+// endpoint 如 http://127.0.0.1:8080
+func NewClient(endpoint string, opt ...ClientOption) *Client {
+	opts := clientOptions{
+		httpClient: http.DefaultClient,
+		marshaler:  JSONBuiltin{},
+		envelope:   LegacyEnvelope,
+	}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		opts:     opts,
+	}
+}
+
+// 调用方法, 失败时返回 WarpError
+func (c *Client) Invoke(ctx context.Context, desc *MethodDesc, in, out proto.Message, opts ...CallOption) error {
+	co := callOptions{header: make(http.Header)}
+	for _, o := range c.opts.callOptions {
+		o.apply(&co)
+	}
+	for _, o := range opts {
+		o.apply(&co)
+	}
+
+	route, err := c.route(desc)
+	if err != nil {
+		return err
+	}
+
+	path, query, reqBody, err := route.Encode(in)
+	if err != nil {
+		return InvalidArgument("%s: %s", desc.Name, err.Error())
+	}
+
+	var body []byte
+	if reqBody != nil {
+		if body, err = c.opts.marshaler.Marshal(reqBody); err != nil {
+			return InvalidArgument("%s: marshal request: %s", desc.Name, err.Error())
+		}
+	}
+
+	url := c.endpoint + path
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+
+	if co.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, co.timeout)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		var data []byte
+		data, err = c.do(ctx, desc.Method, url, body, co.header)
+		if err == nil {
+			return c.unmarshal(desc, data, out)
+		}
+		if attempt >= co.retries || !retryable(ctx, err) {
+			return Convert(err)
+		}
+
+		t := time.NewTimer(co.backoff << attempt)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return Convert(ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) route(desc *MethodDesc) (*Route, error) {
+	if r, ok := c.routes.Load(desc); ok {
+		return r.(*Route), nil
+	}
+
+	if desc.Method == "" {
+		return nil, Unimplemented("%s: missing google.api.http rule", desc.Name)
+	}
+	t, err := ParsePathTemplate(desc.Path)
+	if err != nil {
+		return nil, Internal("%s: %s", desc.Name, err.Error())
+	}
+
+	r := &Route{
+		Method:       desc.Method,
+		Path:         desc.Path,
+		Body:         desc.Body,
+		ResponseBody: desc.ResponseBody,
+		Template:     t,
+	}
+	c.routes.Store(desc, r)
+
+	return r, nil
+}
+
+// 发送请求, 返回解开信封后的回复数据
+func (c *Client) do(ctx context.Context, method, url string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, Internal("%s", err.Error())
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", c.opts.marshaler.ContentType())
+	if body != nil {
+		req.Header.Set("Content-Type", c.opts.marshaler.ContentType())
+	}
+
+	resp, err := c.opts.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, Unavailable("%s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Unavailable("read response: %s", err.Error())
+	}
+
+	u, ok := c.opts.envelope.(EnvelopeUnwrapper)
+	if !ok {
+		u = rawEnvelope{}
+	}
+
+	return u.Unwrap(resp.StatusCode, resp.Header.Get("Content-Type"), data)
+}
+
+// 按 HttpRule.response_body 将回复数据解码到 out
+func (c *Client) unmarshal(desc *MethodDesc, data []byte, out proto.Message) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+
+	var err error
+	if desc.ResponseBody == "" {
+		err = c.opts.marshaler.Unmarshal(data, out)
+	} else {
+		err = c.unmarshalField(desc.ResponseBody, data, out)
+	}
+	if err != nil {
+		return Internal("%s: unmarshal reply: %s", desc.Name, err.Error())
+	}
+
+	return nil
+}
+
+func (c *Client) unmarshalField(name string, data []byte, out proto.Message) error {
+	m := out.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return fmt.Errorf("response body %q: no field in %s", name, m.Descriptor().FullName())
+	}
+
+	if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+		return c.opts.marshaler.Unmarshal(data, m.Mutable(fd).Message().Interface())
+	}
+
+	// 非消息字段: 放回回复中再解码, 只支持 JSON
+	if _, ok := c.opts.marshaler.(ProtoMarshaler); ok {
+		return fmt.Errorf("response body %q: field is not a message", name)
+	}
+	wrapped, err := json.Marshal(map[string]json.RawMessage{string(fd.Name()): data})
+	if err != nil {
+		return err
+	}
+
+	return c.opts.marshaler.Unmarshal(wrapped, out)
+}
+
+// 连接失败, 网关错误和 Unavailable 时重试, 业务错误不重试
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	e := Convert(err)
+	if e.Legacy() {
+		return false
+	}
+	switch e.HTTPStatus() {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return e.Code() == CodeUnavailable
+}
+
+type clientOptions struct {
+	httpClient  *http.Client
+	marshaler   Marshaler
+	envelope    Envelope
+	callOptions []CallOption
+}
+
+type ClientOption interface {
+	apply(*clientOptions)
+}
+
+type funcClientOption struct {
+	f func(*clientOptions)
+}
+
+func (fco *funcClientOption) apply(co *clientOptions) {
+	fco.f(co)
+}
+
+func newFuncClientOption(f func(*clientOptions)) *funcClientOption {
+	return &funcClientOption{f: f}
+}
+
+// 默认 http.DefaultClient
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return newFuncClientOption(func(co *clientOptions) {
+		co.httpClient = hc
+	})
+}
+
+// 请求和回复的编码, 与服务端 WithMarshaler 或 WithCodec 对应
+func WithClientMarshaler(m Marshaler) ClientOption {
+	return newFuncClientOption(func(co *clientOptions) {
+		co.marshaler = m
+	})
+}
+
+// 与服务端 WithEnvelope 一致
+func WithClientEnvelope(e Envelope) ClientOption {
+	return newFuncClientOption(func(co *clientOptions) {
+		co.envelope = e
+	})
+}
+
+// 每次调用默认的选项, 在调用时的选项之前应用
+func WithCallOptions(opts ...CallOption) ClientOption {
+	return newFuncClientOption(func(co *clientOptions) {
+		co.callOptions = append(co.callOptions, opts...)
+	})
+}
+
+type callOptions struct {
+	header  http.Header
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+type CallOption interface {
+	apply(*callOptions)
+}
+
+type funcCallOption struct {
+	f func(*callOptions)
+}
+
+func (fco *funcCallOption) apply(co *callOptions) {
+	fco.f(co)
+}
+
+func newFuncCallOption(f func(*callOptions)) *funcCallOption {
+	return &funcCallOption{f: f}
+}
+
+// 添加请求头, 如 Authorization
+func WithHeader(key, value string) CallOption {
+	return newFuncCallOption(func(co *callOptions) {
+		co.header.Add(key, value)
+	})
+}
+
+// 整个调用的超时, 包括重试
+func WithTimeout(d time.Duration) CallOption {
+	return newFuncCallOption(func(co *callOptions) {
+		co.timeout = d
+	})
+}
+
+// 失败时最多重试 n 次, 间隔从 backoff 开始每次翻倍.
+// 非幂等的方法也会重试, 由调用方决定是否使用
+func WithRetry(n int, backoff time.Duration) CallOption {
+	return newFuncCallOption(func(co *callOptions) {
+		co.retries = n
+		co.backoff = backoff
+	})
+}
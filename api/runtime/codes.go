@@ -0,0 +1,151 @@
+package runtime
+
+import "net/http"
+
+// 标准错误码, 与 google.rpc.Code 一致
+const (
+	CodeOK                 int32 = 0
+	CodeCanceled           int32 = 1
+	CodeUnknown            int32 = 2
+	CodeInvalidArgument    int32 = 3
+	CodeDeadlineExceeded   int32 = 4
+	CodeNotFound           int32 = 5
+	CodeAlreadyExists      int32 = 6
+	CodePermissionDenied   int32 = 7
+	CodeResourceExhausted  int32 = 8
+	CodeFailedPrecondition int32 = 9
+	CodeAborted            int32 = 10
+	CodeOutOfRange         int32 = 11
+	CodeUnimplemented      int32 = 12
+	CodeInternal           int32 = 13
+	CodeUnavailable        int32 = 14
+	CodeDataLoss           int32 = 15
+	CodeUnauthenticated    int32 = 16
+)
+
+// 此前 Engine 对非 WarpError 返回的错误码, 与 CodeCanceled 同值, 以 Legacy 区分
+const codeLegacy int32 = 1
+
+// 业务错误码转换为 google.rpc.Status 时在 ErrorInfo metadata 中的键
+const legacyCodeKey = "code"
+
+var codeStatus = map[int32]int{
+	CodeOK:                 http.StatusOK,
+	CodeCanceled:           499,
+	CodeUnknown:            http.StatusInternalServerError,
+	CodeInvalidArgument:    http.StatusBadRequest,
+	CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+	CodeNotFound:           http.StatusNotFound,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeResourceExhausted:  http.StatusTooManyRequests,
+	CodeFailedPrecondition: http.StatusBadRequest,
+	CodeAborted:            http.StatusConflict,
+	CodeOutOfRange:         http.StatusBadRequest,
+	CodeUnimplemented:      http.StatusNotImplemented,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeDataLoss:           http.StatusInternalServerError,
+	CodeUnauthenticated:    http.StatusUnauthorized,
+}
+
+// 标准错误码对应的 http 状态码, 非标准错误码返回 500
+func HTTPStatusFromCode(code int32) int {
+	if s, ok := codeStatus[code]; ok {
+		return s
+	}
+
+	return http.StatusInternalServerError
+}
+
+// http 状态码对应的标准错误码, 用于无法解析的错误响应, 如代理返回的 502
+func codeFromHTTPStatus(status int) int32 {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidArgument
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeAborted
+	case http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case 499:
+		return CodeCanceled
+	case http.StatusNotImplemented:
+		return CodeUnimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeDeadlineExceeded
+	}
+
+	return CodeUnknown
+}
+
+func Canceled(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeCanceled, format, a...)
+}
+
+func Unknown(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeUnknown, format, a...)
+}
+
+func InvalidArgument(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeInvalidArgument, format, a...)
+}
+
+func DeadlineExceeded(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeDeadlineExceeded, format, a...)
+}
+
+func NotFound(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeNotFound, format, a...)
+}
+
+func AlreadyExists(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeAlreadyExists, format, a...)
+}
+
+func PermissionDenied(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodePermissionDenied, format, a...)
+}
+
+func ResourceExhausted(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeResourceExhausted, format, a...)
+}
+
+func FailedPrecondition(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeFailedPrecondition, format, a...)
+}
+
+func Aborted(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeAborted, format, a...)
+}
+
+func OutOfRange(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeOutOfRange, format, a...)
+}
+
+func Unimplemented(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeUnimplemented, format, a...)
+}
+
+func Internal(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeInternal, format, a...)
+}
+
+func Unavailable(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeUnavailable, format, a...)
+}
+
+func DataLoss(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeDataLoss, format, a...)
+}
+
+func Unauthenticated(format string, a ...interface{}) *WarpError {
+	return newCanonical(CodeUnauthenticated, format, a...)
+}
@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"context"
+	"crypto/x509"
 	"net/http"
 
 	"github.com/devil-dwj/go-wms/base/database/redis"
@@ -39,3 +40,26 @@ func RedisFromContext(ctx context.Context) (r redis.Basic, ok bool) {
 	r, ok = ctx.Value(redisKey{}).(redis.Basic)
 	return
 }
+
+type validatorKey struct{}
+
+func NewValidatorContext(ctx context.Context, v Validator) context.Context {
+	return context.WithValue(ctx, validatorKey{}, v)
+}
+
+func ValidatorFromContext(ctx context.Context) (v Validator, ok bool) {
+	v, ok = ctx.Value(validatorKey{}).(Validator)
+	return
+}
+
+type peerKey struct{}
+
+// mTLS 时客户端证书, 由 Engine 在校验通过后放入
+func NewPeerContext(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, peerKey{}, cert)
+}
+
+func PeerFromContext(ctx context.Context) (cert *x509.Certificate, ok bool) {
+	cert, ok = ctx.Value(peerKey{}).(*x509.Certificate)
+	return
+}
@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"fmt"
+	"net/url"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 按 HttpRule 解码请求: 按 body 选择绑定请求体, 其余字段来自 query, 最后绑定路径变量.
+// 路径变量优先, 请求体不能覆盖, 与 grpc-gateway 一致. bind 由 Engine 提供, 将请求体解码到给定消息
+func (r *Route) Decode(v interface{}, params map[string]string, query url.Values, bind func(interface{}) error) error {
+	if err := r.decodeBody(v, query, bind); err != nil {
+		return err
+	}
+
+	return BindPathParams(v, r.Template, params)
+}
+
+func (r *Route) decodeBody(v interface{}, query url.Values, bind func(interface{}) error) error {
+	filter := r.Template.FieldPaths()
+	switch r.Body {
+	case "*":
+		return bind(v)
+	case "":
+		return PopulateQueryParameters(v, query, filter)
+	}
+
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("body %q: %T is not a proto message", r.Body, v)
+	}
+
+	m := msg.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(r.Body))
+	if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() {
+		return fmt.Errorf("body %q: not a message field of %s", r.Body, m.Descriptor().FullName())
+	}
+
+	if err := bind(m.Mutable(fd).Message().Interface()); err != nil {
+		return err
+	}
+
+	return PopulateQueryParameters(v, query, append(filter, r.Body))
+}
@@ -0,0 +1,89 @@
+package runtime_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func route(t *testing.T, method, path, body string) *runtime.Route {
+	tpl, err := runtime.ParsePathTemplate(path)
+	require.NoError(t, err)
+
+	return &runtime.Route{Method: method, Path: path, Body: body, Template: tpl}
+}
+
+func bindJSON(body string) func(interface{}) error {
+	return func(v interface{}) error {
+		return protojson.Unmarshal([]byte(body), v.(proto.Message))
+	}
+}
+
+func TestDecodePathWins(t *testing.T) {
+	r := route(t, "PUT", "/v1/files/{name}", "*")
+
+	params := map[string]string{"p2": "bin.proto"}
+
+	in := &descriptorpb.FileDescriptorProto{}
+	err := r.Decode(in, params, nil, bindJSON(`{"name":"other.proto","package":"wms.v1"}`))
+	require.NoError(t, err)
+	require.Equal(t, "bin.proto", in.GetName())
+	require.Equal(t, "wms.v1", in.GetPackage())
+}
+
+func TestDecodeBody(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		query   url.Values
+		request string
+		want    *descriptorpb.FileDescriptorProto
+		err     string
+	}{
+		{
+			name:    "all",
+			body:    "*",
+			query:   url.Values{"syntax": {"proto3"}},
+			request: `{"package":"wms.v1"}`,
+			want:    &descriptorpb.FileDescriptorProto{Name: proto.String("bin.proto"), Package: proto.String("wms.v1")},
+		},
+		{
+			name:  "query",
+			query: url.Values{"package": {"wms.v1"}, "name": {"other.proto"}},
+			want:  &descriptorpb.FileDescriptorProto{Name: proto.String("bin.proto"), Package: proto.String("wms.v1")},
+		},
+		{
+			name:    "field",
+			body:    "options",
+			query:   url.Values{"package": {"wms.v1"}, "options.go_package": {"ignored"}},
+			request: `{"goPackage":"wms/v1"}`,
+			want: &descriptorpb.FileDescriptorProto{
+				Name:    proto.String("bin.proto"),
+				Package: proto.String("wms.v1"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("wms/v1")},
+			},
+		},
+		{name: "unknown field", body: "owner", err: `body "owner": not a message field`},
+		{name: "scalar field", body: "package", err: `body "package": not a message field`},
+		{name: "repeated field", body: "message_type", err: `body "message_type": not a message field`},
+	}
+	for _, c := range cases {
+		r := route(t, "POST", "/v1/files/{name}", c.body)
+		params := map[string]string{"p2": "bin.proto"}
+
+		in := &descriptorpb.FileDescriptorProto{}
+		err := r.Decode(in, params, c.query, bindJSON(c.request))
+		if c.err != "" {
+			require.Error(t, err, c.name)
+			require.Contains(t, err.Error(), c.err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.True(t, proto.Equal(c.want, in), "%s: %v", c.name, in)
+	}
+}
@@ -0,0 +1,185 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Decode 的逆过程, 客户端使用: 路径变量取自请求消息, body 为 "*" 时请求体为整个消息,
+// 为字段名时只有该字段, 其余已设置的字段编码为 query. body 为空时 reqBody 为 nil
+func (r *Route) Encode(v interface{}) (path string, query url.Values, reqBody proto.Message, err error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return "", nil, nil, fmt.Errorf("encode: %T is not a proto message", v)
+	}
+	m := msg.ProtoReflect()
+
+	filter := r.Template.FieldPaths()
+	values := make(map[string]string, len(filter))
+	for _, fieldPath := range filter {
+		s, err := fieldValue(m, fieldPath)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("path param %q: %w", fieldPath, err)
+		}
+		values[fieldPath] = s
+	}
+	if path, err = r.Template.Expand(values); err != nil {
+		return "", nil, nil, err
+	}
+
+	query = make(url.Values)
+	switch r.Body {
+	case "*":
+		return path, query, msg, nil
+	case "":
+		err = encodeQuery(m, "", filter, query)
+		return path, query, nil, err
+	}
+
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(r.Body))
+	if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() {
+		return "", nil, nil, fmt.Errorf("body %q: not a message field of %s", r.Body, m.Descriptor().FullName())
+	}
+	if err = encodeQuery(m, "", append(filter, r.Body), query); err != nil {
+		return "", nil, nil, err
+	}
+
+	return path, query, m.Get(fd).Message().Interface(), nil
+}
+
+// 按 a.b.c 形式的字段路径取出标量字段的值
+func fieldValue(m protoreflect.Message, fieldPath string) (string, error) {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := lookupField(m.Descriptor(), name)
+		if fd == nil {
+			return "", fmt.Errorf("no field %q in message %s", name, m.Descriptor().FullName())
+		}
+		if fd.IsList() || fd.IsMap() {
+			return "", fmt.Errorf("field %q is repeated", name)
+		}
+
+		if i < len(names)-1 {
+			if fd.Message() == nil {
+				return "", fmt.Errorf("field %q is not a message", name)
+			}
+			m = m.Get(fd).Message()
+			continue
+		}
+
+		return formatField(fd, m.Get(fd))
+	}
+
+	return "", nil
+}
+
+// 已设置的字段编码为 query, 嵌套字段以 a.b 为键, repeated 字段每项一个值.
+// filter 中的字段路径及其子字段跳过
+func encodeQuery(m protoreflect.Message, prefix string, filter []string, query url.Values) error {
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		key := prefix + string(fd.Name())
+		if filtered(key, filter) {
+			return true
+		}
+
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() && !isWellKnown(fd.Message()) {
+			err = encodeQuery(v.Message(), key+".", filter, query)
+		} else if ferr := encodeField(fd, v, key, query); ferr != nil {
+			err = fmt.Errorf("query param %q: %w", key, ferr)
+		}
+
+		return err == nil
+	})
+
+	return err
+}
+
+func encodeField(fd protoreflect.FieldDescriptor, v protoreflect.Value, key string, query url.Values) error {
+	if fd.IsMap() {
+		return fmt.Errorf("map field %q is not supported", fd.Name())
+	}
+
+	if fd.IsList() {
+		list := v.List()
+		for i := 0; i < list.Len(); i++ {
+			s, err := formatField(fd, list.Get(i))
+			if err != nil {
+				return err
+			}
+			query.Add(key, s)
+		}
+
+		return nil
+	}
+
+	s, err := formatField(fd, v)
+	if err != nil {
+		return err
+	}
+	query.Set(key, s)
+
+	return nil
+}
+
+func formatField(fd protoreflect.FieldDescriptor, v protoreflect.Value) (string, error) {
+	if fd.Message() == nil {
+		return formatScalar(fd, v)
+	}
+
+	return formatWellKnown(v.Message())
+}
+
+// parseWellKnown 的逆过程
+func formatWellKnown(m protoreflect.Message) (string, error) {
+	md := m.Descriptor()
+	switch {
+	case isWrapper(md):
+		fd := md.Fields().Get(0)
+		return formatScalar(fd, m.Get(fd))
+	case isWellKnown(md):
+		data, err := protojson.Marshal(m.Interface())
+		if err != nil {
+			return "", err
+		}
+		return strconv.Unquote(string(data))
+	}
+
+	return "", fmt.Errorf("message %s is not supported", md.FullName())
+}
+
+// parseScalar 的逆过程
+func formatScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) (string, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return v.String(), nil
+	case protoreflect.BytesKind:
+		return base64.URLEncoding.EncodeToString(v.Bytes()), nil
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool()), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return strconv.FormatInt(int64(v.Enum()), 10), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("field %q of type %s is not supported", fd.Name(), fd.Kind())
+}
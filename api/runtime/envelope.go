@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 响应信封, 包装已编码的回复或错误
+type Envelope interface {
+	Success(data []byte) ([]byte, error)
+	Fail(err error) ([]byte, error)
+}
+
+var (
+	// {"code": 0, "msg": "", "data": {...}}, 前端使用的格式
+	LegacyEnvelope Envelope = legacyEnvelope{}
+	// 成功直接返回回复, 失败返回 google.rpc.Status: {"code": 5, "message": "...", "details": [...]}
+	RawEnvelope Envelope = rawEnvelope{}
+)
+
+type legacyEnvelope struct{}
+
+type legacyBody struct {
+	Code int32           `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (legacyEnvelope) Success(data []byte) ([]byte, error) {
+	return json.Marshal(legacyBody{Data: data})
+}
+
+func (legacyEnvelope) Fail(err error) ([]byte, error) {
+	e := Convert(err)
+	return json.Marshal(legacyBody{
+		Code: e.Code(),
+		Msg:  e.Message(),
+		Data: json.RawMessage(`""`),
+	})
+}
+
+type rawEnvelope struct{}
+
+func (rawEnvelope) Success(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (rawEnvelope) Fail(err error) ([]byte, error) {
+	return protojson.Marshal(Convert(err).Proto())
+}
+
+// 客户端解开信封, 返回回复数据, 失败时返回 WarpError.
+// Envelope 可选实现, 未实现时客户端按 RawEnvelope 处理
+type EnvelopeUnwrapper interface {
+	Unwrap(httpStatus int, contentType string, body []byte) ([]byte, error)
+}
+
+func (legacyEnvelope) Unwrap(httpStatus int, contentType string, body []byte) ([]byte, error) {
+	// 非默认编码不使用信封
+	if isProtoContentType(contentType) {
+		return rawEnvelope{}.Unwrap(httpStatus, contentType, body)
+	}
+
+	var b legacyBody
+	if err := json.Unmarshal(body, &b); err != nil {
+		return nil, httpError(httpStatus, body)
+	}
+	if b.Code != CodeOK {
+		// 业务错误码的 http 状态码为 200, 非 WarpError 为 400 和 codeLegacy
+		legacy := httpStatus == http.StatusOK || (httpStatus == http.StatusBadRequest && b.Code == codeLegacy)
+		return nil, (&status{Code: b.Code, Message: b.Msg, HTTPStatus: httpStatus, Legacy: legacy}).Err()
+	}
+
+	return b.Data, nil
+}
+
+func (rawEnvelope) Unwrap(httpStatus int, contentType string, body []byte) ([]byte, error) {
+	if httpStatus >= 200 && httpStatus < 300 {
+		return body, nil
+	}
+
+	s := &spb.Status{}
+	var err error
+	if isProtoContentType(contentType) {
+		err = proto.Unmarshal(body, s)
+	} else {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, s)
+	}
+	if err != nil || s.GetCode() == CodeOK {
+		return nil, httpError(httpStatus, body)
+	}
+
+	return nil, FromProto(s).WithHTTPStatus(httpStatus)
+}
+
+func isProtoContentType(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == ProtoMarshaler{}.ContentType() || mt == "application/protobuf"
+}
+
+// 无法解析的响应
+func httpError(httpStatus int, body []byte) *WarpError {
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(httpStatus)
+	}
+
+	return newCanonical(codeFromHTTPStatus(httpStatus), "%s", msg).WithHTTPStatus(httpStatus)
+}
@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"context"
+)
+
+// 方法信息, 传给拦截器
+type MethodInfo struct {
+	// 服务实现
+	Server interface{}
+	// 服务全名, 如 wms.v1.BinService
+	Service string
+	// MethodDesc.Name, 如 GetBin
+	Name string
+	// 方法全名, 如 /wms.v1.BinService/GetBin
+	FullMethod string
+	HTTPMethod string
+	Path       string
+	// MethodDesc.Anonymous, 认证拦截器据此跳过
+	Anonymous bool
+	// MethodDesc.Permission, 授权拦截器据此检查
+	Permission string
+}
+
+func fullMethod(service, name string) string {
+	return "/" + service + "/" + name
+}
+
+// 调用服务方法
+type UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// 拦截器, 可以看到解码后的请求和返回的回复或错误
+type UnaryInterceptor func(ctx context.Context, req interface{}, info *MethodInfo, next UnaryHandler) (interface{}, error)
+
+// 绑定了 MethodInfo 的拦截器链, 由生成的 handler 调用
+type UnaryInvoker func(ctx context.Context, req interface{}, handler UnaryHandler) (interface{}, error)
+
+// 按顺序组合拦截器, 第一个在最外层
+func ChainUnaryInterceptors(interceptors ...UnaryInterceptor) UnaryInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(ctx context.Context, req interface{}, info *MethodInfo, next UnaryHandler) (interface{}, error) {
+		return interceptors[0](ctx, req, info, chainHandler(interceptors[1:], info, next))
+	}
+}
+
+func chainHandler(interceptors []UnaryInterceptor, info *MethodInfo, final UnaryHandler) UnaryHandler {
+	if len(interceptors) == 0 {
+		return final
+	}
+
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return interceptors[0](ctx, req, info, chainHandler(interceptors[1:], info, final))
+	}
+}
+
+func (i UnaryInterceptor) bind(info *MethodInfo) UnaryInvoker {
+	if i == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, req interface{}, handler UnaryHandler) (interface{}, error) {
+		return i(ctx, req, info, handler)
+	}
+}
+
+type routerOptions struct {
+	interceptors []UnaryInterceptor
+	methods      map[string][]UnaryInterceptor
+}
+
+// RegisterRouter 的选项
+type RouterOption interface {
+	apply(*routerOptions)
+}
+
+type funcRouterOption struct {
+	f func(*routerOptions)
+}
+
+func (fro *funcRouterOption) apply(ro *routerOptions) {
+	fro.f(ro)
+}
+
+func newFuncRouterOption(f func(*routerOptions)) *funcRouterOption {
+	return &funcRouterOption{f: f}
+}
+
+// 服务内所有方法的拦截器
+func ServiceInterceptor(interceptors ...UnaryInterceptor) RouterOption {
+	return newFuncRouterOption(func(ro *routerOptions) {
+		ro.interceptors = append(ro.interceptors, interceptors...)
+	})
+}
+
+// 单个方法的拦截器, method 为 MethodDesc.Name 或方法全名 /wms.v1.BinService/GetBin
+func MethodInterceptor(method string, interceptors ...UnaryInterceptor) RouterOption {
+	return newFuncRouterOption(func(ro *routerOptions) {
+		if ro.methods == nil {
+			ro.methods = make(map[string][]UnaryInterceptor)
+		}
+		ro.methods[method] = append(ro.methods[method], interceptors...)
+	})
+}
@@ -0,0 +1,120 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// 只记录路由和 handler 的 Engine
+type fakeEngine struct {
+	handler runtime.EngineHandler
+	routes  []*runtime.Route
+	// 非空时 Run 阻塞到 Shutdown
+	stop chan struct{}
+	// Tracer 收到的服务名
+	tracer string
+}
+
+func (e *fakeEngine) Handler(h runtime.EngineHandler) { e.handler = h }
+func (e *fakeEngine) Use(...runtime.MiddlewareFunc)   {}
+func (e *fakeEngine) GET(r *runtime.Route)            { e.routes = append(e.routes, r) }
+func (e *fakeEngine) POST(r *runtime.Route)           { e.routes = append(e.routes, r) }
+func (e *fakeEngine) PUT(r *runtime.Route)            { e.routes = append(e.routes, r) }
+func (e *fakeEngine) PATCH(r *runtime.Route)          { e.routes = append(e.routes, r) }
+func (e *fakeEngine) DELETE(r *runtime.Route)         { e.routes = append(e.routes, r) }
+func (e *fakeEngine) Tracer(service string)           { e.tracer = service }
+
+func (e *fakeEngine) Run() error {
+	if e.stop != nil {
+		<-e.stop
+	}
+	return nil
+}
+
+func (e *fakeEngine) Shutdown(context.Context) error {
+	if e.stop != nil {
+		close(e.stop)
+	}
+	return nil
+}
+
+type binServer struct{}
+
+func (binServer) GetBin(ctx context.Context, req string) (string, error) {
+	return "bin " + req, nil
+}
+
+var binDesc = runtime.RouterDesc{
+	ServiceName: "wms.v1.BinService",
+	Methods: []runtime.MethodDesc{{
+		Name:       "GetBin",
+		Method:     "GET",
+		Path:       "/v1/bins/{bin_id}",
+		Middleware: []string{"audit"},
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, invoke runtime.UnaryInvoker) (interface{}, error) {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.(binServer).GetBin(ctx, req.(string))
+			}
+			if invoke == nil {
+				return handler(ctx, "A-01")
+			}
+			return invoke(ctx, "A-01", handler)
+		},
+	}},
+}
+
+// binDesc 的 audit 拦截器, 直接放行
+func audit(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+	return next(ctx, req)
+}
+
+func trace(calls *[]string, name string) runtime.UnaryInterceptor {
+	return func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+		*calls = append(*calls, name+" "+info.FullMethod)
+		reply, err := next(ctx, req)
+		*calls = append(*calls, name+" "+reply.(string))
+		return reply, err
+	}
+}
+
+func TestRouterInterceptors(t *testing.T) {
+	var calls []string
+	e := &fakeEngine{}
+	a := runtime.NewApi(zap.NewNop(),
+		runtime.WithEngine(e),
+		runtime.WithInterceptor("audit", trace(&calls, "audit")),
+		runtime.ChainUnaryInterceptor(trace(&calls, "global")),
+	)
+	a.RegisterRouter(&binDesc, binServer{},
+		runtime.MethodInterceptor("/wms.v1.BinService/GetBin", trace(&calls, "full")),
+		runtime.MethodInterceptor("GetBin", trace(&calls, "method")),
+		runtime.ServiceInterceptor(trace(&calls, "service")),
+	)
+	require.NoError(t, a.Run())
+
+	reply, err := e.handler("GET", "/v1/bins/{bin_id}", nil, context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "bin A-01", reply)
+	require.Equal(t, []string{
+		"global /wms.v1.BinService/GetBin",
+		"service /wms.v1.BinService/GetBin",
+		"audit /wms.v1.BinService/GetBin",
+		"method /wms.v1.BinService/GetBin",
+		"full /wms.v1.BinService/GetBin",
+		"full bin A-01",
+		"method bin A-01",
+		"audit bin A-01",
+		"service bin A-01",
+		"global bin A-01",
+	}, calls)
+}
+
+func TestRouterUnknownInterceptor(t *testing.T) {
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(&fakeEngine{}))
+	a.RegisterRouter(&binDesc, binServer{})
+	require.EqualError(t, a.Run(), `router wms.v1.BinService.GetBin: unknown interceptor "audit"`)
+}
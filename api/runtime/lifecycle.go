@@ -0,0 +1,178 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 默认等待处理中请求完成的时间
+const DefaultDrainTimeout = 30 * time.Second
+
+// 启动/停止钩子
+type Hook func(ctx context.Context) error
+
+// 将 Close() error 包装为停止钩子, 如 redis 客户端, rabbitmq 的 Producer/Consumer
+func CloseHook(c io.Closer) Hook {
+	return func(ctx context.Context) error {
+		return c.Close()
+	}
+}
+
+// 启动和对应的停止钩子, 其中一个可为 nil
+type lifecycleHook struct {
+	start Hook
+	stop  Hook
+}
+
+// 启动前按注册顺序执行, 如连接数据库, 预热缓存
+func (a *Api) OnStart(hooks ...Hook) {
+	for _, h := range hooks {
+		a.hooks = append(a.hooks, lifecycleHook{start: h})
+	}
+}
+
+// 停止时按注册的相反顺序执行, 先注册的资源最后关闭.
+// 启动失败时只执行在失败的启动钩子之前注册的
+func (a *Api) OnStop(hooks ...Hook) {
+	for _, h := range hooks {
+		a.hooks = append(a.hooks, lifecycleHook{stop: h})
+	}
+}
+
+// 成对注册, start 成功后才会在停止或其后的启动钩子失败时执行 stop
+func (a *Api) OnLifecycle(start, stop Hook) {
+	a.hooks = append(a.hooks, lifecycleHook{start: start, stop: stop})
+}
+
+// 启动 Engine 和其它传输, 直到 ctx 取消, 收到 SIGINT/SIGTERM 或任一个退出, 然后优雅退出
+func (a *Api) Start(ctx context.Context) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	for i, h := range a.hooks {
+		if h.start == nil {
+			continue
+		}
+		if err := h.start(ctx); err != nil {
+			// 回滚已经成功的启动钩子
+			a.stop(ctx, a.hooks[:i])
+			return fmt.Errorf("start hook: %w", err)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Println("start api server")
+
+	servers := a.servers()
+	errc := make(chan error, len(servers))
+	for _, s := range servers {
+		s := s
+		go func() {
+			errc <- s.Run()
+		}()
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-errc:
+		if errors.Is(runErr, http.ErrServerClosed) {
+			runErr = nil
+		}
+	}
+
+	timeout := a.opts.drainTimeout
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	sctx, scancel := context.WithTimeout(context.Background(), timeout)
+	defer scancel()
+
+	if err := a.Shutdown(sctx); err != nil && runErr == nil {
+		return err
+	}
+
+	return runErr
+}
+
+// 停止接收新请求, 等待处理中的请求完成后执行停止钩子, 只执行一次
+func (a *Api) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		if a.l != nil {
+			a.l.Info("shutdown api server")
+		}
+
+		// 同时停止, 共用 ctx 的超时
+		servers := a.servers()
+		serverErrs := make([]error, len(servers))
+		var wg sync.WaitGroup
+		for i, s := range servers {
+			wg.Add(1)
+			go func(i int, s server) {
+				defer wg.Done()
+				serverErrs[i] = s.Shutdown(ctx)
+			}(i, s)
+		}
+		wg.Wait()
+
+		var errs []error
+		for _, err := range serverErrs {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("shutdown: %w", err))
+			}
+		}
+		errs = append(errs, a.stop(ctx, a.hooks)...)
+
+		if len(errs) > 0 {
+			a.shutdownErr = errs[0]
+		}
+	})
+
+	return a.shutdownErr
+}
+
+type server interface {
+	Run() error
+	Shutdown(context.Context) error
+}
+
+func (a *Api) servers() []server {
+	var servers []server
+	if a.opts.Engine != nil {
+		servers = append(servers, a.opts.Engine)
+	}
+	for _, t := range a.opts.transports {
+		servers = append(servers, t)
+	}
+
+	return servers
+}
+
+func (a *Api) stop(ctx context.Context, hooks []lifecycleHook) []error {
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hooks[i].stop == nil {
+			continue
+		}
+		if err := hooks[i].stop(ctx); err != nil {
+			if a.l != nil {
+				a.l.Error("stop hook", zap.Error(err))
+			}
+			errs = append(errs, fmt.Errorf("stop hook: %w", err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,57 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLifecycle(t *testing.T) {
+	var calls []string
+	hook := func(name string) runtime.Hook {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	e := &fakeEngine{stop: make(chan struct{})}
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(e), runtime.WithDrainTimeout(time.Second))
+	a.OnStart(hook("start mysql"), hook("start redis"))
+	a.OnStop(hook("stop mysql"), hook("stop redis"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- a.Start(ctx) }()
+	cancel()
+
+	require.NoError(t, <-done)
+	require.NoError(t, a.Shutdown(context.Background()))
+	require.Equal(t, []string{"start mysql", "start redis", "stop redis", "stop mysql"}, calls)
+}
+
+func TestStartHookError(t *testing.T) {
+	var calls []string
+	hook := func(name string, err error) runtime.Hook {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	a := runtime.NewApi(zap.NewNop(), runtime.WithEngine(&fakeEngine{}))
+	a.OnStop(hook("close redis", nil))
+	a.OnLifecycle(hook("start mysql", nil), hook("stop mysql", nil))
+	a.OnLifecycle(hook("start mq", errors.New("dial tcp: refused")), hook("stop mq", nil))
+	a.OnStart(hook("warm cache", nil))
+	a.OnStop(hook("flush cache", nil))
+
+	// 只回滚已经成功的启动钩子
+	require.EqualError(t, a.Start(context.Background()), "start hook: dial tcp: refused")
+	require.Equal(t, []string{"start mysql", "start mq", "stop mysql", "close redis"}, calls)
+}
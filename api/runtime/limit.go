@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"io"
+	"net/http"
+)
+
+// 请求体超过限制的错误, 返回 413
+func errBodyTooLarge(n int64) *WarpError {
+	return InvalidArgument("request body too large, limit %d bytes", n).WithHTTPStatus(http.StatusRequestEntityTooLarge)
+}
+
+// 限制请求体大小, 超出时读取返回 413 的 WarpError, 与 http.MaxBytesReader 不同, 不依赖 ResponseWriter
+func MaxBytesReader(r io.ReadCloser, n int64) io.ReadCloser {
+	return &maxBytesReader{r: r, n: n, limit: n}
+}
+
+// 检查请求体大小, Content-Length 已超出时直接返回错误, 否则限制读取
+func LimitRequestBody(r *http.Request, n int64) error {
+	if n <= 0 || r.Body == nil {
+		return nil
+	}
+	if r.ContentLength > n {
+		return errBodyTooLarge(n)
+	}
+
+	r.Body = MaxBytesReader(r.Body, n)
+
+	return nil
+}
+
+type maxBytesReader struct {
+	r     io.ReadCloser
+	n     int64
+	limit int64
+	err   error
+}
+
+func (l *maxBytesReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// 多读一个字节, 判断是否超出
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		l.err = err
+		return n, err
+	}
+
+	n = int(l.n)
+	l.n = 0
+	l.err = errBodyTooLarge(l.limit)
+
+	return n, l.err
+}
+
+func (l *maxBytesReader) Close() error {
+	return l.r.Close()
+}
@@ -0,0 +1,32 @@
+package runtime_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/bins", strings.NewReader(`{"bin_id":"A-01"}`))
+	err := runtime.LimitRequestBody(r, 8)
+	require.Equal(t, http.StatusRequestEntityTooLarge, runtime.Convert(err).HTTPStatus())
+
+	// 未知长度时读取超出才报错
+	r = httptest.NewRequest("POST", "/v1/bins", ioutil.NopCloser(strings.NewReader(`{"bin_id":"A-01"}`)))
+	r.ContentLength = -1
+	require.NoError(t, runtime.LimitRequestBody(r, 8))
+	b, err := ioutil.ReadAll(r.Body)
+	require.Len(t, b, 8)
+	require.Equal(t, http.StatusRequestEntityTooLarge, runtime.Convert(err).HTTPStatus())
+
+	r = httptest.NewRequest("POST", "/v1/bins", strings.NewReader(`{"bin_id":"A-01"}`))
+	require.NoError(t, runtime.LimitRequestBody(r, 17))
+	b, err = ioutil.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Len(t, b, 17)
+}
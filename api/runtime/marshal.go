@@ -0,0 +1,86 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/devil-dwj/go-wms/base/serializer"
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 编解码请求和响应
+type Marshaler interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// encoding/json 编码, 字段名取 json tag (即 proto 原始名), 枚举为数字.
+// 与此前 gin c.JSON 的输出一致
+type JSONBuiltin struct{}
+
+func (JSONBuiltin) ContentType() string {
+	return "application/json"
+}
+
+func (JSONBuiltin) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONBuiltin) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// protojson 编码, 遵循 json_name, 枚举名等 proto3 JSON 规范.
+// 非 proto 消息回退到 encoding/json
+type JSONPb struct {
+	protojson.MarshalOptions
+	protojson.UnmarshalOptions
+}
+
+func (*JSONPb) ContentType() string {
+	return "application/json"
+}
+
+func (j *JSONPb) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return j.MarshalOptions.Marshal(m)
+	}
+
+	return json.Marshal(v)
+}
+
+func (j *JSONPb) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return j.UnmarshalOptions.Unmarshal(data, m)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// protobuf 二进制编码, 只支持 proto 消息
+type ProtoMarshaler struct{}
+
+func (ProtoMarshaler) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (ProtoMarshaler) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto message", v)
+	}
+
+	return serializer.ProtobufToBinary(protov1.MessageV1(m))
+}
+
+func (ProtoMarshaler) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a proto message", v)
+	}
+
+	return serializer.BinaryToProtobufMessage(data, protov1.MessageV1(m))
+}
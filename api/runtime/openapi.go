@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Swagger UI 的路径, 文档在其下, 如 /swagger/bin.BinService.openapi.json
+const OpenAPIPrefix = "/swagger/"
+
+// Swagger UI 从 CDN 加载
+var swaggerUI = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4.5.0/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@4.5.0/swagger-ui-bundle.js"></script>
+<script>
+window.onload = function() {
+	window.ui = SwaggerUIBundle({
+		urls: {{.}},
+		dom_id: "#swagger-ui",
+		presets: [SwaggerUIBundle.presets.apis],
+	});
+};
+</script>
+</body>
+</html>
+`))
+
+// 提供 path 中的 OpenAPI 文档和 Swagger UI, 供 Engine 挂载到 OpenAPIPrefix.
+// path 为目录时提供其中所有 .json 文件, 每次请求重新读取
+func OpenAPIHandler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		docs, err := openapiDocs(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, OpenAPIPrefix)
+		if name == "" || name == "index.html" {
+			type url struct {
+				URL  string `json:"url"`
+				Name string `json:"name"`
+			}
+			urls := make([]url, 0, len(docs))
+			for _, doc := range docs {
+				urls = append(urls, url{URL: OpenAPIPrefix + doc, Name: strings.TrimSuffix(doc, ".openapi.json")})
+			}
+			data, _ := json.Marshal(urls)
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_ = swaggerUI.Execute(w, template.JS(data))
+			return
+		}
+
+		// 只提供列出的文档, 防止访问其它文件
+		for _, doc := range docs {
+			if doc == name {
+				w.Header().Set("Content-Type", "application/json")
+				http.ServeFile(w, r, openapiFile(path, doc))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+}
+
+// 文档文件名, 按名字排序
+func openapiDocs(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{filepath.Base(path)}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		docs = append(docs, filepath.Base(m))
+	}
+	sort.Strings(docs)
+
+	return docs, nil
+}
+
+func openapiFile(path, doc string) string {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return filepath.Join(path, doc)
+	}
+
+	return path
+}
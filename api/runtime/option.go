@@ -1,6 +1,9 @@
 package runtime
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/devil-dwj/go-wms/base/database/redis"
 )
 
@@ -10,8 +13,22 @@ type apiOptions struct {
 	recovery MiddlewareFunc
 	chain    []MiddlewareFunc
 	trace    bool
+	service  string
 	r        redis.Basic
 	static   string
+	openapi  string
+	handlers []httpHandler
+
+	marshaler Marshaler
+	envelope  Envelope
+	codecs    map[string]Marshaler
+	validator Validator
+
+	interceptors      map[string]UnaryInterceptor
+	unaryInterceptors []UnaryInterceptor
+
+	drainTimeout time.Duration
+	transports   []Transport
 }
 
 type ApiOption interface {
@@ -60,6 +77,13 @@ func WithTracing(b bool) ApiOption {
 	})
 }
 
+// 服务名, tracing 的 tracer 名称, 默认 DefaultServiceName
+func WithServiceName(name string) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.service = name
+	})
+}
+
 func WithRedis(r redis.Basic) ApiOption {
 	return newFuncApiOption(func(ao *apiOptions) {
 		ao.r = r
@@ -71,3 +95,91 @@ func WithStatic(path string) ApiOption {
 		ao.static = path
 	})
 }
+
+// 在 OpenAPIPrefix 提供 Swagger UI, path 为 protoc-gen-go-router openapi=true 生成的文档, 文件或目录
+func WithOpenAPI(path string) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.openapi = path
+	})
+}
+
+type httpHandler struct {
+	path string
+	h    http.Handler
+}
+
+// 在 path 挂载普通 http.Handler, 不经过信封和拦截器, 如 JWKS
+func WithHTTPHandler(path string, h http.Handler) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.handlers = append(ao.handlers, httpHandler{path: path, h: h})
+	})
+}
+
+// 响应编码, 默认 JSONBuiltin
+func WithMarshaler(m Marshaler) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.marshaler = m
+	})
+}
+
+// 响应信封, 默认 LegacyEnvelope, RawEnvelope 不包装
+func WithEnvelope(e Envelope) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.envelope = e
+	})
+}
+
+// 按 Content-Type / Accept 协商的附加编码, 如 application/msgpack
+func WithCodec(contentType string, m Marshaler) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		if ao.codecs == nil {
+			ao.codecs = make(map[string]Marshaler)
+		}
+		ao.codecs[contentType] = m
+	})
+}
+
+// 请求校验器, 如 FieldValidator 将校验失败转换为列出全部字段的 InvalidArgument
+func WithValidator(v Validator) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.validator = v
+	})
+}
+
+// 注册命名拦截器, 由 proto 方法选项 (wms.api.method).middleware 引用
+func WithInterceptor(name string, i UnaryInterceptor) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		if ao.interceptors == nil {
+			ao.interceptors = make(map[string]UnaryInterceptor)
+		}
+		ao.interceptors[name] = i
+	})
+}
+
+// 全局拦截器, 包在所有方法的最外层, 可以多次调用
+func WithUnaryInterceptor(i UnaryInterceptor) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.unaryInterceptors = append(ao.unaryInterceptors, i)
+	})
+}
+
+// 按顺序添加多个全局拦截器, 第一个在最外层
+func ChainUnaryInterceptor(interceptors ...UnaryInterceptor) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.unaryInterceptors = append(ao.unaryInterceptors, interceptors...)
+	})
+}
+
+// 退出时等待处理中请求的最长时间, 默认 DefaultDrainTimeout
+func WithDrainTimeout(d time.Duration) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.drainTimeout = d
+	})
+}
+
+// 同时通过其它传输提供服务, 如 gRPC, 与 Engine 共用拦截器
+func WithTransport(t ...Transport) ApiOption {
+	return newFuncApiOption(func(ao *apiOptions) {
+		ao.transports = append(ao.transports, t...)
+	})
+}
@@ -0,0 +1,235 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 将路由参数绑定到请求消息的字段
+func BindPathParams(v interface{}, t *PathTemplate, params map[string]string) error {
+	if t == nil || len(t.variables) == 0 {
+		return nil
+	}
+
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("path params: %T is not a proto message", v)
+	}
+
+	for fieldPath, value := range t.Values(params) {
+		if err := PopulateFieldFromPath(msg, fieldPath, value); err != nil {
+			return fmt.Errorf("path param %q: %w", fieldPath, err)
+		}
+	}
+
+	return nil
+}
+
+// 按 a.b.c 形式的字段路径设置字段值, 中间的消息字段按需创建.
+// 字段名可用原始名或 json_name, repeated 字段每个值追加一项
+func PopulateFieldFromPath(msg proto.Message, fieldPath string, values ...string) error {
+	m := msg.ProtoReflect()
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := lookupField(m.Descriptor(), name)
+		if fd == nil {
+			return fmt.Errorf("no field %q in message %s", name, m.Descriptor().FullName())
+		}
+
+		if i < len(names)-1 {
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() || isWellKnown(fd.Message()) {
+				return fmt.Errorf("field %q is not a message", name)
+			}
+			m = m.Mutable(fd).Message()
+			continue
+		}
+
+		return populateField(m, fd, values)
+	}
+
+	return nil
+}
+
+func populateField(m protoreflect.Message, fd protoreflect.FieldDescriptor, values []string) error {
+	if fd.IsMap() {
+		return fmt.Errorf("map field %q is not supported", fd.Name())
+	}
+
+	if fd.IsList() {
+		list := m.Mutable(fd).List()
+		for _, value := range values {
+			v, err := parseField(fd, list.NewElement, value)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+
+		return nil
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	if len(values) > 1 {
+		return fmt.Errorf("too many values for field %q", fd.Name())
+	}
+
+	// oneof 只能设置一个成员
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		if set := m.WhichOneof(od); set != nil && set != fd {
+			return fmt.Errorf("field %q conflicts with %q in oneof %q", fd.Name(), set.Name(), od.Name())
+		}
+	}
+
+	v, err := parseField(fd, func() protoreflect.Value { return m.NewField(fd) }, values[0])
+	if err != nil {
+		return err
+	}
+	m.Set(fd, v)
+
+	return nil
+}
+
+func parseField(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value, value string) (protoreflect.Value, error) {
+	if fd.Message() == nil {
+		return parseScalar(fd, value)
+	}
+
+	v := newValue()
+	if err := parseWellKnown(v.Message(), value); err != nil {
+		return v, fmt.Errorf("field %q: %w", fd.Name(), err)
+	}
+
+	return v, nil
+}
+
+// 按原始名查找字段, 找不到再按 json_name 查找
+func lookupField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+
+	return fields.ByJSONName(name)
+}
+
+func isWellKnown(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp",
+		"google.protobuf.Duration",
+		"google.protobuf.FieldMask":
+		return true
+	}
+
+	return isWrapper(md)
+}
+
+// google.protobuf.XxxValue 包装类型
+func isWrapper(md protoreflect.MessageDescriptor) bool {
+	if md.ParentFile().Package() != "google.protobuf" || !strings.HasSuffix(string(md.Name()), "Value") {
+		return false
+	}
+
+	return md.Fields().Len() == 1 && md.Fields().Get(0).Name() == "value"
+}
+
+// Timestamp 为 RFC3339, Duration 为 "1.5s", FieldMask 为 "a.b,c", 包装类型同其标量
+func parseWellKnown(m protoreflect.Message, value string) error {
+	md := m.Descriptor()
+	switch {
+	case isWrapper(md):
+		fd := md.Fields().Get(0)
+		v, err := parseScalar(fd, value)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+		return nil
+	case isWellKnown(md):
+		if err := protojson.Unmarshal([]byte(strconv.Quote(value)), m.Interface()); err != nil {
+			return fmt.Errorf("invalid value %q for %s", value, md.FullName())
+		}
+		return nil
+	}
+
+	return fmt.Errorf("message %s is not supported", md.FullName())
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	var (
+		v   protoreflect.Value
+		err error
+	)
+
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		var b []byte
+		b, err = base64.URLEncoding.DecodeString(value)
+		if err != nil {
+			b, err = base64.StdEncoding.DecodeString(value)
+		}
+		v = protoreflect.ValueOfBytes(b)
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.EnumKind:
+		return parseEnum(fd, value)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(i))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, 64)
+		v = protoreflect.ValueOfInt64(i)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var u uint64
+		u, err = strconv.ParseUint(value, 10, 32)
+		v = protoreflect.ValueOfUint32(uint32(u))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var u uint64
+		u, err = strconv.ParseUint(value, 10, 64)
+		v = protoreflect.ValueOfUint64(u)
+	case protoreflect.FloatKind:
+		var f float64
+		f, err = strconv.ParseFloat(value, 32)
+		v = protoreflect.ValueOfFloat32(float32(f))
+	case protoreflect.DoubleKind:
+		var f float64
+		f, err = strconv.ParseFloat(value, 64)
+		v = protoreflect.ValueOfFloat64(f)
+	default:
+		return v, fmt.Errorf("field %q of type %s is not supported", fd.Name(), fd.Kind())
+	}
+
+	if err != nil {
+		return v, fmt.Errorf("invalid value %q for %s field %q", value, fd.Kind(), fd.Name())
+	}
+
+	return v, nil
+}
+
+// 枚举可用名字或数字
+func parseEnum(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	values := fd.Enum().Values()
+	if ev := values.ByName(protoreflect.Name(value)); ev != nil {
+		return protoreflect.ValueOfEnum(ev.Number()), nil
+	}
+
+	i, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || values.ByNumber(protoreflect.EnumNumber(i)) == nil {
+		return protoreflect.Value{}, fmt.Errorf("invalid value %q for enum field %q", value, fd.Name())
+	}
+
+	return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+}
@@ -0,0 +1,66 @@
+package runtime
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 基于 protoreflect 从 query 参数填充请求消息, 与具体 Engine 无关.
+//
+//	?id=1&id=2          repeated 字段
+//	?item.sku=a         嵌套字段, 可用原始名或 json_name
+//	?state=DONE         枚举, 名字或数字
+//	?at=2022-01-02T15:04:05Z&ttl=1.5s   Timestamp, Duration 等 well-known types
+//
+// 未知参数忽略, filter 中的字段路径及其子字段跳过
+func PopulateQueryParameters(v interface{}, values url.Values, filter []string) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("query params: %T is not a proto message", v)
+	}
+
+	for key, vals := range values {
+		fieldPath, ok := canonicalPath(msg.ProtoReflect().Descriptor(), key)
+		if len(vals) == 0 || !ok || filtered(fieldPath, filter) {
+			continue
+		}
+
+		if err := PopulateFieldFromPath(msg, fieldPath, vals...); err != nil {
+			return fmt.Errorf("query param %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func filtered(key string, filter []string) bool {
+	for _, f := range filter {
+		if key == f || strings.HasPrefix(key, f+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 将 json_name 形式的字段路径转换为原始名, 字段不存在返回 false
+func canonicalPath(md protoreflect.MessageDescriptor, fieldPath string) (string, bool) {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		if md == nil {
+			return "", false
+		}
+		fd := lookupField(md, name)
+		if fd == nil {
+			return "", false
+		}
+		names[i] = string(fd.Name())
+		md = fd.Message()
+	}
+
+	return strings.Join(names, "."), true
+}
@@ -0,0 +1,185 @@
+package runtime_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestPopulateQueryParameters(t *testing.T) {
+	msg := newQueryMessage(t)
+	values := url.Values{
+		"bin_id":       {"A-01"},
+		"warehouseId":  {"7"},
+		"ids":          {"1", "2"},
+		"state":        {"DONE"},
+		"states":       {"1", "READY"},
+		"item.sku":     {"sku-1"},
+		"item.qty":     {"3"},
+		"at":           {"2022-01-02T15:04:05Z"},
+		"ttl":          {"1.5s"},
+		"limit":        {"10"},
+		"enabled":      {"true"},
+		"ratio":        {"0.5"},
+		"by_sku":       {"x"},
+		"unknown":      {"ignored"},
+		"item.unknown": {"ignored"},
+	}
+
+	require.NoError(t, runtime.PopulateQueryParameters(msg, values, nil))
+
+	m := msg.ProtoReflect()
+	require.Equal(t, "A-01", get(m, "bin_id").String())
+	require.Equal(t, int64(7), get(m, "warehouse_id").Int())
+	require.Equal(t, 2, get(m, "ids").List().Len())
+	require.Equal(t, uint64(2), get(m, "ids").List().Get(1).Uint())
+	require.Equal(t, protoreflect.EnumNumber(2), get(m, "state").Enum())
+	require.Equal(t, protoreflect.EnumNumber(1), get(m, "states").List().Get(1).Enum())
+	require.Equal(t, "sku-1", get(get(m, "item").Message(), "sku").String())
+	require.Equal(t, int64(3), get(get(m, "item").Message(), "qty").Int())
+	require.Equal(t, "x", get(m, "by_sku").String())
+	require.True(t, get(m, "enabled").Bool())
+	require.Equal(t, float32(0.5), float32(get(m, "ratio").Float()))
+
+	at := &timestamppb.Timestamp{}
+	proto.Merge(at, get(m, "at").Message().Interface())
+	require.Equal(t, time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC), at.AsTime())
+
+	ttl := &durationpb.Duration{}
+	proto.Merge(ttl, get(m, "ttl").Message().Interface())
+	require.Equal(t, 1500*time.Millisecond, ttl.AsDuration())
+
+	limit := &wrapperspb.Int32Value{}
+	proto.Merge(limit, get(m, "limit").Message().Interface())
+	require.Equal(t, int32(10), limit.GetValue())
+}
+
+func TestPopulateQueryParametersFilter(t *testing.T) {
+	msg := newQueryMessage(t)
+	values := url.Values{
+		"warehouseId": {"7"},
+		"item.sku":    {"sku-1"},
+		"bin_id":      {"A-01"},
+	}
+
+	require.NoError(t, runtime.PopulateQueryParameters(msg, values, []string{"warehouse_id", "item"}))
+
+	m := msg.ProtoReflect()
+	require.False(t, m.Has(field(m, "warehouse_id")))
+	require.False(t, m.Has(field(m, "item")))
+	require.Equal(t, "A-01", get(m, "bin_id").String())
+}
+
+func TestPopulateQueryParametersInvalid(t *testing.T) {
+	cases := []url.Values{
+		{"warehouse_id": {"abc"}},
+		{"warehouse_id": {"1", "2"}},
+		{"ids": {"-1"}},
+		{"state": {"UNKNOWN_STATE"}},
+		{"state": {"9"}},
+		{"at": {"yesterday"}},
+		{"by_sku": {"x"}, "by_bin": {"y"}},
+	}
+
+	for _, values := range cases {
+		err := runtime.PopulateQueryParameters(newQueryMessage(t), values, nil)
+		require.Error(t, err, values.Encode())
+	}
+}
+
+func get(m protoreflect.Message, name string) protoreflect.Value {
+	return m.Get(field(m, name))
+}
+
+func field(m protoreflect.Message, name string) protoreflect.FieldDescriptor {
+	return m.Descriptor().Fields().ByName(protoreflect.Name(name))
+}
+
+// 构造测试用的动态消息, 覆盖标量, 枚举, repeated, 嵌套, oneof 和 well-known types
+func newQueryMessage(t *testing.T) proto.Message {
+	t.Helper()
+
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	f := func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  label,
+			Type:   typ.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+
+	bySku := f("by_sku", 14, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	bySku.OneofIndex = proto.Int32(0)
+	byBin := f("by_bin", 15, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	byBin.OneofIndex = proto.Int32(0)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("runtime/query_test.proto"),
+		Package: proto.String("runtime.test"),
+		Syntax:  proto.String("proto3"),
+		Dependency: []string{
+			"google/protobuf/timestamp.proto",
+			"google/protobuf/duration.proto",
+			"google/protobuf/wrappers.proto",
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("State"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATE_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("READY"), Number: proto.Int32(1)},
+				{Name: proto.String("DONE"), Number: proto.Int32(2)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					f("sku", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					f("qty", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+				},
+			},
+			{
+				Name: proto.String("Query"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					f("bin_id", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					f("warehouse_id", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					f("ids", 3, repeated, descriptorpb.FieldDescriptorProto_TYPE_UINT32, ""),
+					f("state", 4, optional, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".runtime.test.State"),
+					f("states", 5, repeated, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".runtime.test.State"),
+					f("item", 6, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".runtime.test.Item"),
+					f("at", 7, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+					f("ttl", 8, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Duration"),
+					f("limit", 9, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Int32Value"),
+					f("enabled", 10, optional, descriptorpb.FieldDescriptorProto_TYPE_BOOL, ""),
+					f("ratio", 11, optional, descriptorpb.FieldDescriptorProto_TYPE_FLOAT, ""),
+					bySku,
+					byBin,
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("lookup")}},
+			},
+		},
+	}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+
+	return dynamicpb.NewMessage(fd.Messages().ByName("Query"))
+}
@@ -1,10 +1,29 @@
 package runtime
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
 
 type status struct {
 	Code    int32
 	Message string
+	// http 状态码, 0 时按 Code 查表
+	HTTPStatus int
+	// 机器可读的错误原因, 如 BIN_NOT_FOUND
+	Reason   string
+	Metadata map[string]string
+	Details  []proto.Message
+	// 业务错误码, 与标准错误码不在同一空间
+	Legacy bool
 }
 
 type WarpError struct {
@@ -15,6 +34,12 @@ func new(c int32, msg string) *status {
 	return &status{Code: c, Message: msg}
 }
 
+func newCanonical(c int32, format string, a ...interface{}) *WarpError {
+	s := new(c, fmt.Sprintf(format, a...))
+	s.HTTPStatus = HTTPStatusFromCode(c)
+	return &WarpError{e: s}
+}
+
 func (e *WarpError) Error() string {
 	return e.e.Message
 }
@@ -23,12 +48,129 @@ func (e *WarpError) Code() int32 {
 	return e.e.Code
 }
 
+func (e *WarpError) Message() string {
+	return e.e.Message
+}
+
+func (e *WarpError) HTTPStatus() int {
+	if e.e.HTTPStatus != 0 {
+		return e.e.HTTPStatus
+	}
+
+	return HTTPStatusFromCode(e.e.Code)
+}
+
+func (e *WarpError) Reason() string {
+	return e.e.Reason
+}
+
+func (e *WarpError) Metadata() map[string]string {
+	return e.e.Metadata
+}
+
+func (e *WarpError) Details() []proto.Message {
+	return e.e.Details
+}
+
+// 是否为业务错误码 (Error 创建), 此时 Code 不是标准错误码
+func (e *WarpError) Legacy() bool {
+	return e.e.Legacy
+}
+
+// 以下 With 方法返回副本, 可用于从预定义的错误派生
+
+func (e *WarpError) WithHTTPStatus(status int) *WarpError {
+	c := e.clone()
+	c.e.HTTPStatus = status
+	return c
+}
+
+func (e *WarpError) WithReason(reason string) *WarpError {
+	c := e.clone()
+	c.e.Reason = reason
+	return c
+}
+
+func (e *WarpError) WithMetadata(md map[string]string) *WarpError {
+	c := e.clone()
+	c.e.Metadata = make(map[string]string, len(e.e.Metadata)+len(md))
+	for k, v := range e.e.Metadata {
+		c.e.Metadata[k] = v
+	}
+	for k, v := range md {
+		c.e.Metadata[k] = v
+	}
+	return c
+}
+
+func (e *WarpError) WithDetails(details ...proto.Message) *WarpError {
+	c := e.clone()
+	c.e.Details = append(append([]proto.Message{}, e.e.Details...), details...)
+	return c
+}
+
+func (e *WarpError) clone() *WarpError {
+	s := *e.e
+	return &WarpError{e: &s}
+}
+
+// errors.Is: 错误码和原因相同即视为同一错误, 业务错误码与标准错误码不相等
+func (e *WarpError) Is(target error) bool {
+	t, ok := target.(*WarpError)
+	if !ok {
+		return false
+	}
+
+	return t.e.Legacy == e.e.Legacy && t.e.Code == e.e.Code && t.e.Reason == e.e.Reason
+}
+
+// 转换为 google.rpc.Status, 原因和元数据以 google.rpc.ErrorInfo 放在详情中.
+// 业务错误码不是标准错误码, 转换为 Unknown, 原错误码放在 ErrorInfo 的 metadata 中
+func (e *WarpError) Proto() *spb.Status {
+	s := &spb.Status{
+		Code:    e.e.Code,
+		Message: e.e.Message,
+	}
+
+	md := e.e.Metadata
+	if e.e.Legacy {
+		s.Code = CodeUnknown
+		md = make(map[string]string, len(e.e.Metadata)+1)
+		for k, v := range e.e.Metadata {
+			md[k] = v
+		}
+		md[legacyCodeKey] = strconv.Itoa(int(e.e.Code))
+	}
+
+	details := e.e.Details
+	if e.e.Reason != "" || len(md) > 0 {
+		details = append([]proto.Message{&errdetails.ErrorInfo{
+			Reason:   e.e.Reason,
+			Metadata: md,
+		}}, details...)
+	}
+
+	for _, d := range details {
+		a, err := anypb.New(d)
+		if err != nil {
+			continue
+		}
+		s.Details = append(s.Details, a)
+	}
+
+	return s
+}
+
 func (s *status) Err() error {
 	return &WarpError{e: s}
 }
 
+// 业务错误码, 为兼容前端 http 状态码为 200
 func Error(c int32, msg string) error {
-	return new(c, msg).Err()
+	s := new(c, msg)
+	s.HTTPStatus = http.StatusOK
+	s.Legacy = true
+	return s.Err()
 }
 
 func Errorf(c int32, format string, a ...interface{}) error {
@@ -38,3 +180,79 @@ func Errorf(c int32, format string, a ...interface{}) error {
 func Code(c int32) error {
 	return Error(c, "")
 }
+
+// 取出错误链中的 WarpError
+func FromError(err error) (*WarpError, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	var e *WarpError
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	return nil, false
+}
+
+// 任意错误转换为 WarpError, 非 WarpError 为 Unknown
+func Convert(err error) *WarpError {
+	if e, ok := FromError(err); ok {
+		return e
+	}
+
+	switch {
+	case err == nil:
+		return newCanonical(CodeOK, "")
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded("%s", err.Error())
+	case errors.Is(err, context.Canceled):
+		return Canceled("%s", err.Error())
+	}
+
+	return Unknown("%s", err.Error())
+}
+
+// HTTP 响应使用的转换, 非 WarpError 沿用此前 Engine 的约定: HTTP 400, code 1
+func convertHTTP(err error) *WarpError {
+	if _, ok := FromError(err); ok || err == nil {
+		return Convert(err)
+	}
+
+	s := new(codeLegacy, err.Error())
+	s.HTTPStatus = http.StatusBadRequest
+	s.Legacy = true
+	return &WarpError{e: s}
+}
+
+// 由 google.rpc.Status 还原, 详情中的 google.rpc.ErrorInfo 还原为原因和元数据,
+// Unknown 且带有原错误码时还原为业务错误码
+func FromProto(s *spb.Status) *WarpError {
+	e := newCanonical(s.GetCode(), "%s", s.GetMessage())
+	for _, a := range s.GetDetails() {
+		d, err := a.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			e.e.Reason = info.GetReason()
+			e.e.Metadata = info.GetMetadata()
+			continue
+		}
+		e.e.Details = append(e.e.Details, d)
+	}
+
+	if c, ok := e.e.Metadata[legacyCodeKey]; ok && e.e.Code == CodeUnknown {
+		if code, err := strconv.ParseInt(c, 10, 32); err == nil {
+			e.e.Code = int32(code)
+			e.e.HTTPStatus = http.StatusOK
+			e.e.Legacy = true
+			delete(e.e.Metadata, legacyCodeKey)
+			if len(e.e.Metadata) == 0 {
+				e.e.Metadata = nil
+			}
+		}
+	}
+
+	return e
+}
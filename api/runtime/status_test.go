@@ -0,0 +1,80 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+var errBinNotFound = runtime.NotFound("bin not found").WithReason("BIN_NOT_FOUND")
+
+func TestWarpErrorIsAs(t *testing.T) {
+	err := fmt.Errorf("load bin: %w", errBinNotFound.WithMetadata(map[string]string{"bin": "A-01"}))
+
+	require.True(t, errors.Is(err, errBinNotFound))
+	require.False(t, errors.Is(err, runtime.NotFound("bin not found")))
+
+	var e *runtime.WarpError
+	require.True(t, errors.As(err, &e))
+	require.Equal(t, runtime.CodeNotFound, e.Code())
+	require.Equal(t, http.StatusNotFound, e.HTTPStatus())
+	require.Equal(t, "A-01", e.Metadata()["bin"])
+	require.Empty(t, errBinNotFound.Metadata())
+}
+
+func TestConvert(t *testing.T) {
+	require.Equal(t, runtime.CodeUnknown, runtime.Convert(errors.New("boom")).Code())
+	require.Equal(t, http.StatusInternalServerError, runtime.Convert(errors.New("boom")).HTTPStatus())
+	require.Equal(t, runtime.CodeDeadlineExceeded, runtime.Convert(context.DeadlineExceeded).Code())
+	require.Equal(t, "100%", runtime.Convert(errors.New("100%")).Message())
+
+	// 业务错误码保持 200
+	legacy := runtime.Convert(runtime.Error(1001, "stock not enough"))
+	require.Equal(t, int32(1001), legacy.Code())
+	require.Equal(t, http.StatusOK, legacy.HTTPStatus())
+}
+
+func TestWarpErrorProto(t *testing.T) {
+	bad := &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+		{Field: "bin_id", Description: "required"},
+	}}
+	err := runtime.InvalidArgument("invalid %s", "bin").
+		WithReason("INVALID_BIN").
+		WithMetadata(map[string]string{"bin": "A-01"}).
+		WithDetails(bad)
+
+	s := err.Proto()
+	require.Equal(t, runtime.CodeInvalidArgument, s.GetCode())
+	require.Len(t, s.GetDetails(), 2)
+
+	back := runtime.FromProto(s)
+	require.True(t, errors.Is(back, err))
+	require.Equal(t, "invalid bin", back.Message())
+	require.Equal(t, "A-01", back.Metadata()["bin"])
+	require.Len(t, back.Details(), 1)
+	require.True(t, proto.Equal(bad, back.Details()[0]))
+}
+
+// 业务错误码与同值的标准错误码不相等
+func TestLegacyCode(t *testing.T) {
+	err := runtime.Error(runtime.CodeNotFound, "no such bin")
+	require.False(t, errors.Is(err, runtime.NotFound("")))
+	require.True(t, errors.Is(err, runtime.Code(runtime.CodeNotFound)))
+	require.True(t, runtime.Convert(err).Legacy())
+	require.False(t, runtime.NotFound("").Legacy())
+
+	s := runtime.Convert(err).Proto()
+	require.Equal(t, runtime.CodeUnknown, s.GetCode())
+
+	back := runtime.FromProto(s)
+	require.True(t, errors.Is(back, err))
+	require.Equal(t, http.StatusOK, back.HTTPStatus())
+	require.Empty(t, back.Metadata())
+}
@@ -0,0 +1,39 @@
+package runtime
+
+import "context"
+
+// 服务端流, Engine 以 SSE 或 WebSocket 实现, gRPC 传输直接使用 grpc.ServerStream.
+// 客户端断开后 Context 被取消
+type ServerStream interface {
+	Context() context.Context
+	SendMsg(m interface{}) error
+}
+
+type streamHandler func(
+	srv interface{},
+	stream ServerStream,
+	dec func(interface{}) error,
+	invoke UnaryInvoker,
+) error
+
+// 流式路由的 handler, 由支持流的 Engine 实现 StreamHandler(EngineStreamHandler) 接收
+type EngineStreamHandler func(
+	method string,
+	path string,
+	dec func(interface{}) error,
+	stream ServerStream,
+) error
+
+type contextStream struct {
+	ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// 替换流的 context, 拦截器修改的 context 由此传给流式方法
+func StreamWithContext(ctx context.Context, stream ServerStream) ServerStream {
+	return &contextStream{ServerStream: stream, ctx: ctx}
+}
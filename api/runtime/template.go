@@ -0,0 +1,347 @@
+package runtime
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// google.api.http 路径模板
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+type PathTemplate struct {
+	template  string
+	segments  []segment
+	variables []variable
+}
+
+type segmentKind int
+
+const (
+	segmentLiteral segmentKind = iota
+	segmentWildcard
+	segmentDeepWildcard
+)
+
+type segment struct {
+	kind  segmentKind
+	value string // 字面量
+	param string // 路由参数名, 按位置命名为 p0, p1...
+}
+
+// 变量绑定到请求消息字段, 占用 segments[start:end]
+type variable struct {
+	fieldPath string
+	start     int
+	end       int
+}
+
+func ParsePathTemplate(template string) (*PathTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("path template %q: must start with /", template)
+	}
+
+	t := &PathTemplate{template: template}
+	rest := template[1:]
+	for len(rest) > 0 {
+		var raw string
+		if rest[0] == '{' {
+			i := strings.IndexByte(rest, '}')
+			if i < 0 {
+				return nil, fmt.Errorf("path template %q: unclosed variable", template)
+			}
+			raw, rest = rest[:i+1], rest[i+1:]
+			if err := t.parseVariable(raw[1 : len(raw)-1]); err != nil {
+				return nil, fmt.Errorf("path template %q: %w", template, err)
+			}
+		} else {
+			i := strings.IndexByte(rest, '/')
+			if i < 0 {
+				i = len(rest)
+			}
+			raw, rest = rest[:i], rest[i:]
+			if err := t.parseSegment(raw); err != nil {
+				return nil, fmt.Errorf("path template %q: %w", template, err)
+			}
+		}
+
+		if len(rest) > 0 {
+			if rest[0] != '/' {
+				return nil, fmt.Errorf("path template %q: unexpected %q", template, rest)
+			}
+			rest = rest[1:]
+			if len(rest) == 0 {
+				return nil, fmt.Errorf("path template %q: trailing /", template)
+			}
+		}
+	}
+
+	for i, s := range t.segments {
+		if s.kind == segmentDeepWildcard && i != len(t.segments)-1 {
+			return nil, fmt.Errorf("path template %q: ** must be the last segment", template)
+		}
+	}
+
+	return t, nil
+}
+
+func (t *PathTemplate) parseVariable(raw string) error {
+	fieldPath, sub := raw, "*"
+	if i := strings.IndexByte(raw, '='); i >= 0 {
+		fieldPath, sub = raw[:i], raw[i+1:]
+	}
+	if fieldPath == "" || sub == "" {
+		return fmt.Errorf("invalid variable {%s}", raw)
+	}
+	for _, name := range strings.Split(fieldPath, ".") {
+		if !isIdent(name) {
+			return fmt.Errorf("invalid field path %q", fieldPath)
+		}
+	}
+	for _, v := range t.variables {
+		if v.fieldPath == fieldPath {
+			return fmt.Errorf("duplicate variable %q", fieldPath)
+		}
+	}
+
+	v := variable{fieldPath: fieldPath, start: len(t.segments)}
+	for _, p := range strings.Split(sub, "/") {
+		if err := t.parseSegment(p); err != nil {
+			return err
+		}
+	}
+	v.end = len(t.segments)
+	t.variables = append(t.variables, v)
+
+	return nil
+}
+
+// 路由参数按段的位置命名, 不用字段路径. gin 要求同一位置的参数同名,
+// 否则 /v1/bins/{id} 和 /v1/bins/{bin_id}/items 注册时冲突
+func (t *PathTemplate) parseSegment(raw string) error {
+	param := "p" + strconv.Itoa(len(t.segments))
+
+	switch {
+	case raw == "*":
+		t.segments = append(t.segments, segment{kind: segmentWildcard, param: param})
+	case raw == "**":
+		t.segments = append(t.segments, segment{kind: segmentDeepWildcard, param: param})
+	case raw == "":
+		return fmt.Errorf("empty segment")
+	case strings.ContainsAny(raw, ":{}*"):
+		// 自定义方法 /v1/{name}:verb 与路由参数冲突, 不支持
+		return fmt.Errorf("unsupported segment %q", raw)
+	default:
+		t.segments = append(t.segments, segment{kind: segmentLiteral, value: raw})
+	}
+
+	return nil
+}
+
+// 原始模板
+func (t *PathTemplate) String() string {
+	return t.template
+}
+
+// 转换为 :p0 / *p1 形式的路由, gin 等路由可直接使用, 由 Values 还原为字段路径
+func (t *PathTemplate) Pattern() string {
+	var b strings.Builder
+	for _, s := range t.segments {
+		b.WriteByte('/')
+		switch s.kind {
+		case segmentLiteral:
+			b.WriteString(s.value)
+		case segmentWildcard:
+			b.WriteString(":" + s.param)
+		case segmentDeepWildcard:
+			b.WriteString("*" + s.param)
+		}
+	}
+
+	return b.String()
+}
+
+// 匹配请求路径, 返回与 Pattern 参数名一致的路由参数, 不依赖 gin 等路由时使用
+func (t *PathTemplate) Match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+
+	parts := strings.Split(path[1:], "/")
+	params := make(map[string]string)
+	for i, s := range t.segments {
+		if s.kind == segmentDeepWildcard && i < len(parts) {
+			params[s.param] = "/" + strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		switch s.kind {
+		case segmentLiteral:
+			if parts[i] != s.value {
+				return nil, false
+			}
+		case segmentWildcard:
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[s.param] = parts[i]
+		}
+	}
+
+	if len(parts) != len(t.segments) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// 去掉变量名的形状, 变量按位置命名, 形状相同的路径在 gin 中是同一路由
+func (t *PathTemplate) shape() string {
+	var b strings.Builder
+	for _, s := range t.segments {
+		b.WriteByte('/')
+		switch s.kind {
+		case segmentLiteral:
+			b.WriteString(s.value)
+		case segmentWildcard:
+			b.WriteString("*")
+		case segmentDeepWildcard:
+			b.WriteString("**")
+		}
+	}
+
+	return b.String()
+}
+
+// 两个路径是否不能同时注册: 形状相同, 或一个的 ** 与另一个同一位置的段并存.
+// gin 的 catch-all 参数不能与同一位置的字面量或参数共存, 如 /v1/files/{name=**} 与 /v1/files/latest
+func (t *PathTemplate) conflicts(o *PathTemplate) bool {
+	return t.shape() == o.shape() || t.catchAllConflicts(o) || o.catchAllConflicts(t)
+}
+
+func (t *PathTemplate) catchAllConflicts(o *PathTemplate) bool {
+	n := len(t.segments) - 1
+	if t.segments[n].kind != segmentDeepWildcard || len(o.segments) <= n {
+		return false
+	}
+	for i, s := range t.segments[:n] {
+		if s.kind != o.segments[i].kind || s.value != o.segments[i].value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 字段路径列表
+func (t *PathTemplate) FieldPaths() []string {
+	paths := make([]string, 0, len(t.variables))
+	for _, v := range t.variables {
+		paths = append(paths, v.fieldPath)
+	}
+
+	return paths
+}
+
+// 根据路由参数还原各变量的值, 以字段路径为键
+func (t *PathTemplate) Values(params map[string]string) map[string]string {
+	values := make(map[string]string, len(t.variables))
+	for _, v := range t.variables {
+		parts := make([]string, 0, v.end-v.start)
+		for _, s := range t.segments[v.start:v.end] {
+			switch s.kind {
+			case segmentLiteral:
+				parts = append(parts, s.value)
+			case segmentWildcard:
+				parts = append(parts, params[s.param])
+			case segmentDeepWildcard:
+				parts = append(parts, strings.TrimPrefix(params[s.param], "/"))
+			}
+		}
+		values[v.fieldPath] = strings.Join(parts, "/")
+	}
+
+	return values
+}
+
+// Values 的逆过程, 由各变量的值生成请求路径, 值按段转义.
+// 值须与变量的子模板匹配, 如 {name=shelves/*} 的值为 shelves/1
+func (t *PathTemplate) Expand(values map[string]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(t.segments); i++ {
+		s := t.segments[i]
+		v, ok := t.variableAt(i)
+		if !ok {
+			if s.kind != segmentLiteral {
+				return "", fmt.Errorf("path template %q: wildcard outside variable", t.template)
+			}
+			b.WriteString("/" + s.value)
+			continue
+		}
+
+		parts, err := matchVariable(t.segments[v.start:v.end], values[v.fieldPath])
+		if err != nil {
+			return "", fmt.Errorf("path variable %q: %w", v.fieldPath, err)
+		}
+		for _, p := range parts {
+			b.WriteString("/" + url.PathEscape(p))
+		}
+		i = v.end - 1
+	}
+
+	return b.String(), nil
+}
+
+func (t *PathTemplate) variableAt(i int) (variable, bool) {
+	for _, v := range t.variables {
+		if v.start == i {
+			return v, true
+		}
+	}
+
+	return variable{}, false
+}
+
+// 按变量的子模板拆分值
+func matchVariable(segments []segment, value string) ([]string, error) {
+	if value == "" {
+		return nil, fmt.Errorf("empty value")
+	}
+
+	parts := strings.Split(value, "/")
+	deep := segments[len(segments)-1].kind == segmentDeepWildcard
+	if len(parts) < len(segments) || !deep && len(parts) > len(segments) {
+		return nil, fmt.Errorf("value %q does not match template", value)
+	}
+	for i, s := range segments {
+		if s.kind == segmentLiteral && parts[i] != s.value ||
+			s.kind == segmentWildcard && parts[i] == "" {
+			return nil, fmt.Errorf("value %q does not match template", value)
+		}
+	}
+
+	return parts, nil
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
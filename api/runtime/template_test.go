@@ -0,0 +1,68 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePathTemplateErrors(t *testing.T) {
+	cases := []struct {
+		template string
+		err      string
+	}{
+		{"v1/bins", "must start with /"},
+		{"/v1/bins/{bin_id", "unclosed variable"},
+		{"/v1/bins/", "trailing /"},
+		{"/v1//bins", "empty segment"},
+		{"/v1/{}", "invalid variable"},
+		{"/v1/{name=}", "invalid variable"},
+		{"/v1/{1name}", "invalid field path"},
+		{"/v1/{bin.}", "invalid field path"},
+		{"/v1/{id}/{id}", "duplicate variable"},
+		{"/v1/{name=**}/items", "** must be the last segment"},
+		{"/v1/bins/{bin_id}:move", "unexpected"},
+		{"/v1/bins:move", "unsupported segment"},
+	}
+	for _, c := range cases {
+		_, err := runtime.ParsePathTemplate(c.template)
+		require.Error(t, err, c.template)
+		require.Contains(t, err.Error(), c.err, c.template)
+	}
+}
+
+func TestPathTemplateValues(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		values   map[string]string
+	}{
+		{"/v1/bins/{bin_id}", "/v1/bins/A-01", map[string]string{"bin_id": "A-01"}},
+		{"/v1/{name=warehouses/*/bins/*}", "/v1/warehouses/7/bins/A-01", map[string]string{"name": "warehouses/7/bins/A-01"}},
+		{"/v1/{parent=warehouses/*}/bins/{bin.id}", "/v1/warehouses/7/bins/A-01", map[string]string{"parent": "warehouses/7", "bin.id": "A-01"}},
+		{"/v1/files/{name=**}", "/v1/files/wms/v1/bin.proto", map[string]string{"name": "wms/v1/bin.proto"}},
+		{"/v1/{name=files/**}", "/v1/files/wms/bin.proto", map[string]string{"name": "files/wms/bin.proto"}},
+	}
+	for _, c := range cases {
+		tpl, err := runtime.ParsePathTemplate(c.template)
+		require.NoError(t, err, c.template)
+
+		params, ok := tpl.Match(c.path)
+		require.True(t, ok, c.template)
+		require.Equal(t, c.values, tpl.Values(params), c.template)
+
+		path, err := tpl.Expand(c.values)
+		require.NoError(t, err, c.template)
+		require.Equal(t, c.path, path, c.template)
+	}
+}
+
+func TestPathTemplatePattern(t *testing.T) {
+	tpl, err := runtime.ParsePathTemplate("/v1/{parent=warehouses/*}/bins/{bin_id}/{path=**}")
+	require.NoError(t, err)
+	require.Equal(t, "/v1/warehouses/:p2/bins/:p4/*p5", tpl.Pattern())
+
+	_, ok := tpl.Match("/v1/warehouses/7/stocks/A-01/x")
+	require.False(t, ok)
+}
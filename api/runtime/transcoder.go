@@ -0,0 +1,266 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	mimeForm      = "application/x-www-form-urlencoded"
+	mimeMultipart = "multipart/form-data"
+
+	defaultMultipartMemory = 32 << 20
+)
+
+// 请求解码与响应编码, 按 Content-Type / Accept 从已注册的编码中选择, 供 Engine 使用.
+// 默认注册 application/json 和 application/x-protobuf, 表单只用于解码请求
+type Transcoder struct {
+	marshaler  Marshaler
+	envelope   Envelope
+	marshalers map[string]Marshaler
+}
+
+// m 为空使用 JSONBuiltin, e 为空使用 LegacyEnvelope
+func NewTranscoder(m Marshaler, e Envelope) *Transcoder {
+	if m == nil {
+		m = JSONBuiltin{}
+	}
+	if e == nil {
+		e = LegacyEnvelope
+	}
+
+	t := &Transcoder{
+		marshaler:  m,
+		envelope:   e,
+		marshalers: make(map[string]Marshaler),
+	}
+	t.Register(ProtoMarshaler{}.ContentType(), ProtoMarshaler{})
+	t.Register("application/protobuf", ProtoMarshaler{})
+	t.Register(m.ContentType(), m)
+
+	return t
+}
+
+// 注册编码, 如 application/msgpack
+func (t *Transcoder) Register(contentType string, m Marshaler) {
+	t.marshalers[contentType] = m
+}
+
+// 解码请求, Accept 不支持时返回 406, Content-Type 不支持时返回 415, 其它解码错误为 InvalidArgument
+func (t *Transcoder) Decode(r *http.Request, route *Route, params map[string]string, v interface{}) error {
+	if _, err := t.accept(r); err != nil {
+		return err
+	}
+
+	err := route.Decode(v, params, r.URL.Query(), func(v interface{}) error {
+		return t.bind(r, v)
+	})
+	if _, ok := FromError(err); err != nil && !ok {
+		return InvalidArgument("%s", err.Error())
+	}
+
+	return err
+}
+
+func (t *Transcoder) bind(r *http.Request, v interface{}) error {
+	contentType := t.marshaler.ContentType()
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return InvalidArgument("invalid content type %q", ct).WithHTTPStatus(http.StatusUnsupportedMediaType)
+		}
+		contentType = mt
+	}
+
+	switch contentType {
+	case mimeForm:
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		return PopulateQueryParameters(v, r.PostForm, nil)
+	case mimeMultipart:
+		if err := r.ParseMultipartForm(defaultMultipartMemory); err != nil {
+			return err
+		}
+		return PopulateQueryParameters(v, r.MultipartForm.Value, nil)
+	}
+
+	m, ok := t.marshalers[contentType]
+	if !ok {
+		return InvalidArgument("unsupported content type %q", contentType).WithHTTPStatus(http.StatusUnsupportedMediaType)
+	}
+
+	if r.Body == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	return m.Unmarshal(data, v)
+}
+
+// 按 Accept 选择响应编码, 未指定或 */* 使用默认编码.
+// 按 q 值从高到低选择, q 相同时按出现顺序, q=0 表示不接受
+func (t *Transcoder) accept(r *http.Request) (Marshaler, error) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return t.marshaler, nil
+	}
+
+	type mediaRange struct {
+		mt string
+		q  float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mt: mt, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, rg := range ranges {
+		switch rg.mt {
+		case "*/*", "application/*":
+			return t.marshaler, nil
+		}
+		if m, ok := t.marshalers[rg.mt]; ok {
+			return m, nil
+		}
+	}
+
+	return nil, InvalidArgument("unsupported accept %q", accept).WithHTTPStatus(http.StatusNotAcceptable)
+}
+
+func (t *Transcoder) WriteReply(w http.ResponseWriter, r *http.Request, route *Route, reply interface{}) {
+	m, err := t.accept(r)
+	if err != nil {
+		t.WriteError(w, r, err)
+		return
+	}
+
+	data, err := t.marshalReply(m, route, reply)
+	// 信封只包装默认编码, 其它编码直接返回回复
+	if err == nil && t.isDefault(m) {
+		data, err = t.envelope.Success(data)
+	}
+	if err != nil {
+		t.WriteError(w, r, Internal("marshal reply: %s", err))
+		return
+	}
+
+	write(w, m.ContentType(), http.StatusOK, data)
+}
+
+// 错误转换为 WarpError, 状态码取 HTTPStatus, 非 WarpError 为 HTTP 400, code 1.
+// 默认编码使用信封, 其它编码 (如 protobuf) 直接返回 google.rpc.Status
+func (t *Transcoder) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := convertHTTP(err)
+
+	m, aerr := t.accept(r)
+	if aerr != nil || t.isDefault(m) {
+		m = t.marshaler
+	}
+
+	var (
+		data []byte
+		merr error
+	)
+	if t.isDefault(m) {
+		data, merr = t.envelope.Fail(e)
+	} else {
+		data, merr = m.Marshal(e.Proto())
+	}
+	if merr != nil {
+		http.Error(w, e.Message(), http.StatusInternalServerError)
+		return
+	}
+
+	write(w, m.ContentType(), e.HTTPStatus(), data)
+}
+
+// 默认编码, 流式消息使用
+func (t *Transcoder) Marshaler() Marshaler {
+	return t.marshaler
+}
+
+// 按信封编码错误, 流开始后的错误作为最后一条消息发送
+func (t *Transcoder) MarshalError(err error) ([]byte, error) {
+	return t.envelope.Fail(convertHTTP(err))
+}
+
+func (t *Transcoder) isDefault(m Marshaler) bool {
+	return m.ContentType() == t.marshaler.ContentType()
+}
+
+func write(w http.ResponseWriter, contentType string, status int, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// 按 HttpRule.response_body 选择回复中的字段编码
+func (t *Transcoder) marshalReply(m Marshaler, route *Route, reply interface{}) ([]byte, error) {
+	msg, ok := reply.(proto.Message)
+	if route == nil || route.ResponseBody == "" || !ok {
+		return m.Marshal(reply)
+	}
+
+	pm := msg.ProtoReflect()
+	fd := pm.Descriptor().Fields().ByName(protoreflect.Name(route.ResponseBody))
+	if fd == nil {
+		return nil, fmt.Errorf("response body %q: no field in %s", route.ResponseBody, pm.Descriptor().FullName())
+	}
+
+	if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+		return m.Marshal(pm.Get(fd).Message().Interface())
+	}
+
+	// 非消息字段: 编码整个回复后取出该字段, 只支持 JSON
+	if !t.isDefault(m) {
+		return nil, fmt.Errorf("response body %q: field is not a message", route.ResponseBody)
+	}
+	data, err := m.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("response body %q: %w", route.ResponseBody, err)
+	}
+
+	for _, key := range []string{fd.JSONName(), string(fd.Name())} {
+		if v, ok := fields[key]; ok {
+			return v, nil
+		}
+	}
+
+	return []byte("null"), nil
+}
@@ -0,0 +1,164 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var file = &descriptorpb.FileDescriptorProto{
+	Name:       proto.String("bin.proto"),
+	Package:    proto.String("wms.v1"),
+	Dependency: []string{"a.proto"},
+	Options:    &descriptorpb.FileOptions{GoPackage: proto.String("wms/v1")},
+}
+
+func TestResponseBody(t *testing.T) {
+	cases := []struct {
+		responseBody string
+		code         int
+		body         string
+	}{
+		{"", http.StatusOK, `{"code":0,"msg":"","data":{"name":"bin.proto","package":"wms.v1","dependency":["a.proto"],"options":{"go_package":"wms/v1"}}}`},
+		{"options", http.StatusOK, `{"code":0,"msg":"","data":{"go_package":"wms/v1"}}`},
+		{"package", http.StatusOK, `{"code":0,"msg":"","data":"wms.v1"}`},
+		{"dependency", http.StatusOK, `{"code":0,"msg":"","data":["a.proto"]}`},
+		{"syntax", http.StatusOK, `{"code":0,"msg":"","data":null}`},
+		{"owner", http.StatusInternalServerError, `{"code":13,"msg":"marshal reply: response body \"owner\": no field in google.protobuf.FileDescriptorProto","data":""}`},
+	}
+	for _, c := range cases {
+		tc := runtime.NewTranscoder(nil, nil)
+		w := httptest.NewRecorder()
+		tc.WriteReply(w, httptest.NewRequest("GET", "/v1/files/bin.proto", nil), &runtime.Route{ResponseBody: c.responseBody}, file)
+
+		require.Equal(t, c.code, w.Code, c.responseBody)
+		require.JSONEq(t, c.body, w.Body.String(), c.responseBody)
+	}
+}
+
+func TestNegotiateReply(t *testing.T) {
+	cases := []struct {
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"", http.StatusOK, "application/json"},
+		{"*/*", http.StatusOK, "application/json"},
+		{"application/*", http.StatusOK, "application/json"},
+		{"application/x-protobuf", http.StatusOK, "application/x-protobuf"},
+		{"application/protobuf", http.StatusOK, "application/x-protobuf"},
+		{"text/html, application/x-protobuf", http.StatusOK, "application/x-protobuf"},
+		{"application/json;q=0.5, application/x-protobuf", http.StatusOK, "application/x-protobuf"},
+		{"application/x-protobuf;q=0.1, application/json", http.StatusOK, "application/json"},
+		{"application/x-protobuf;q=0, */*;q=0.1", http.StatusOK, "application/json"},
+		{"text/html", http.StatusNotAcceptable, "application/json"},
+		{"application/x-protobuf;q=0", http.StatusNotAcceptable, "application/json"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/v1/files/bin.proto", nil)
+		r.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		runtime.NewTranscoder(nil, nil).WriteReply(w, r, &runtime.Route{}, file)
+
+		require.Equal(t, c.code, w.Code, c.accept)
+		require.Equal(t, c.contentType, w.Header().Get("Content-Type"), c.accept)
+
+		switch {
+		case c.code == http.StatusNotAcceptable:
+			require.JSONEq(t, `{"code":3,"msg":"unsupported accept \"`+c.accept+`\"","data":""}`, w.Body.String(), c.accept)
+		case c.contentType == "application/x-protobuf":
+			// 非默认编码不使用信封
+			got := &descriptorpb.FileDescriptorProto{}
+			require.NoError(t, proto.Unmarshal(w.Body.Bytes(), got))
+			require.True(t, proto.Equal(file, got), c.accept)
+		}
+	}
+}
+
+func TestNegotiateError(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/files/bin.proto", nil)
+	r.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	runtime.NewTranscoder(nil, nil).WriteError(w, r, runtime.NotFound("file %s not found", "bin.proto"))
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+	s := &spb.Status{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), s))
+	require.Equal(t, runtime.CodeNotFound, s.GetCode())
+	require.Equal(t, "file bin.proto not found", s.GetMessage())
+}
+
+func TestNegotiateRequest(t *testing.T) {
+	data, err := proto.Marshal(file)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name        string
+		contentType string
+		accept      string
+		body        string
+		code        int
+		want        *descriptorpb.FileDescriptorProto
+	}{
+		{name: "default", body: `{"name":"bin.proto","package":"wms.v1"}`, want: &descriptorpb.FileDescriptorProto{Name: proto.String("bin.proto"), Package: proto.String("wms.v1")}},
+		{name: "json", contentType: "application/json; charset=utf-8", body: `{"package":"wms.v1"}`, want: &descriptorpb.FileDescriptorProto{Package: proto.String("wms.v1")}},
+		{name: "protobuf", contentType: "application/x-protobuf", body: string(data), want: file},
+		{name: "form", contentType: "application/x-www-form-urlencoded", body: "package=wms.v1&dependency=a.proto", want: &descriptorpb.FileDescriptorProto{Package: proto.String("wms.v1"), Dependency: []string{"a.proto"}}},
+		{name: "unsupported", contentType: "text/plain", body: "wms.v1", code: http.StatusUnsupportedMediaType},
+		{name: "invalid", contentType: "application/", body: "wms.v1", code: http.StatusUnsupportedMediaType},
+		{name: "not acceptable", accept: "text/html", body: `{}`, code: http.StatusNotAcceptable},
+		{name: "malformed", contentType: "application/json", body: `{"package":`, code: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/v1/files", strings.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+
+		in := &descriptorpb.FileDescriptorProto{}
+		err := runtime.NewTranscoder(&runtime.JSONPb{}, nil).Decode(r, route(t, "POST", "/v1/files", "*"), nil, in)
+		if c.code != 0 {
+			require.Error(t, err, c.name)
+			require.Equal(t, c.code, runtime.Convert(err).HTTPStatus(), c.name)
+			require.Equal(t, runtime.CodeInvalidArgument, runtime.Convert(err).Code(), c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.True(t, proto.Equal(c.want, in), "%s: %v", c.name, in)
+	}
+}
+
+// 前端依赖的 {code,msg,data} 约定
+func TestLegacyError(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{errors.New("stock not enough"), http.StatusBadRequest, `{"code":1,"msg":"stock not enough","data":""}`},
+		{fmt.Errorf("load bin: %w", context.DeadlineExceeded), http.StatusBadRequest, `{"code":1,"msg":"load bin: context deadline exceeded","data":""}`},
+		{runtime.Error(1001, "stock not enough"), http.StatusOK, `{"code":1001,"msg":"stock not enough","data":""}`},
+		{runtime.NotFound("not find register method: GET /v1/stocks"), http.StatusNotFound, `{"code":5,"msg":"not find register method: GET /v1/stocks","data":""}`},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		runtime.NewTranscoder(nil, nil).WriteError(w, httptest.NewRequest("GET", "/v1/bins/A-01", nil), c.err)
+
+		require.Equal(t, c.code, w.Code, c.err.Error())
+		require.JSONEq(t, c.body, w.Body.String(), c.err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package runtime
+
+import "context"
+
+// Engine 之外的传输, 如 gRPC, 使用相同的 RouterDesc, 拦截器和中间件.
+// 传输须用 NewRequestContext 把调用转换为 *http.Request 放入 ctx,
+// Use/ChainMiddle 的中间件和 WithLog 以此执行, 没有请求时配置了中间件的调用被拒绝
+type Transport interface {
+	RegisterService(*Service)
+	Run() error
+	Shutdown(context.Context) error
+}
+
+// 注册到 Transport 的服务
+type Service struct {
+	// 服务全名, 如 wms.v1.BinService
+	Name    string
+	Impl    interface{}
+	Methods []*ServiceMethod
+	// 定义服务的 proto 文件, 来自 RouterDesc.Metadata
+	Metadata string
+}
+
+type ServiceMethod struct {
+	// MethodDesc.Name, 如 GetBin
+	Name string
+	// 方法全名, 如 /wms.v1.BinService/GetBin
+	FullMethod string
+	// 用 dec 解码请求, 经过拦截器调用服务方法.
+	// interceptor 为传输自己的拦截器, 如 gRPC 的 UnaryServerInterceptor, 非 nil 时在方法的拦截器之外执行
+	Call func(ctx context.Context, dec func(interface{}) error, interceptor UnaryInterceptor) (interface{}, error)
+	// 服务端流方法, 与 Call 二选一
+	Stream func(stream ServerStream, dec func(interface{}) error) error
+}
@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// 请求校验, 由生成的 handler 在解码后调用
+type Validator interface {
+	Validate(ctx context.Context, req interface{}) error
+}
+
+type ValidatorFunc func(ctx context.Context, req interface{}) error
+
+func (f ValidatorFunc) Validate(ctx context.Context, req interface{}) error {
+	return f(ctx, req)
+}
+
+// protoc-gen-validate 生成的方法
+type validator interface {
+	Validate() error
+}
+
+type validatorAll interface {
+	ValidateAll() error
+}
+
+// protoc-gen-validate 的字段错误
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// 收集全部字段错误, 返回带 google.rpc.BadRequest 详情的 InvalidArgument
+var FieldValidator Validator = ValidatorFunc(func(ctx context.Context, req interface{}) error {
+	var err error
+	if v, ok := req.(validatorAll); ok {
+		err = v.ValidateAll()
+	} else if v, ok := req.(validator); ok {
+		err = v.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+
+	bad := &errdetails.BadRequest{FieldViolations: fieldViolations("", err)}
+	msgs := make([]string, 0, len(bad.FieldViolations))
+	for _, v := range bad.FieldViolations {
+		msgs = append(msgs, v.Field+": "+v.Description)
+	}
+
+	return InvalidArgument("invalid request: %s", strings.Join(msgs, "; ")).
+		WithReason("VALIDATION_FAILED").
+		WithDetails(bad)
+})
+
+// 校验请求: 使用 WithValidator 设置的校验器, 否则调用请求的 Validate 方法
+func Validate(ctx context.Context, req interface{}) error {
+	if v, ok := ValidatorFromContext(ctx); ok {
+		return v.Validate(ctx, req)
+	}
+
+	if v, ok := req.(validator); ok {
+		if err := v.Validate(); err != nil {
+			if _, ok := FromError(err); ok {
+				return err
+			}
+			return InvalidArgument("%s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// 展开 MultiError 和嵌套消息的错误, 字段路径以 . 连接
+func fieldViolations(prefix string, err error) []*errdetails.BadRequest_FieldViolation {
+	if m, ok := err.(interface {
+		AllErrors() []error
+	}); ok {
+		var vs []*errdetails.BadRequest_FieldViolation
+		for _, e := range m.AllErrors() {
+			vs = append(vs, fieldViolations(prefix, e)...)
+		}
+		return vs
+	}
+
+	f, ok := err.(fieldError)
+	if !ok {
+		return []*errdetails.BadRequest_FieldViolation{{Field: prefix, Description: err.Error()}}
+	}
+
+	field := f.Field()
+	if prefix != "" {
+		field = prefix + "." + field
+	}
+
+	if c, ok := err.(interface {
+		Cause() error
+	}); ok && c.Cause() != nil {
+		if _, nested := c.Cause().(fieldError); nested {
+			return fieldViolations(field, c.Cause())
+		}
+		if _, nested := c.Cause().(interface {
+			AllErrors() []error
+		}); nested {
+			return fieldViolations(field, c.Cause())
+		}
+	}
+
+	return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: f.Reason()}}
+}
@@ -0,0 +1,70 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// 模拟 protoc-gen-validate 生成的错误类型
+type fieldErr struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e fieldErr) Error() string  { return e.field + ": " + e.reason }
+func (e fieldErr) Field() string  { return e.field }
+func (e fieldErr) Reason() string { return e.reason }
+func (e fieldErr) Cause() error   { return e.cause }
+
+type multiErr []error
+
+func (m multiErr) Error() string      { return "multiple errors" }
+func (m multiErr) AllErrors() []error { return m }
+
+type binRequest struct {
+	err error
+}
+
+func (r *binRequest) Validate() error    { return r.err }
+func (r *binRequest) ValidateAll() error { return r.err }
+
+func TestFieldValidator(t *testing.T) {
+	req := &binRequest{err: multiErr{
+		fieldErr{field: "bin_id", reason: "value length must be at least 1 runes"},
+		fieldErr{field: "item", reason: "embedded message failed validation", cause: fieldErr{field: "qty", reason: "value must be greater than 0"}},
+	}}
+
+	err := runtime.FieldValidator.Validate(context.Background(), req)
+
+	var e *runtime.WarpError
+	require.True(t, errors.As(err, &e))
+	require.Equal(t, runtime.CodeInvalidArgument, e.Code())
+	require.Contains(t, e.Message(), "bin_id: value length must be at least 1 runes")
+	require.Contains(t, e.Message(), "item.qty: value must be greater than 0")
+
+	bad := e.Details()[0].(*errdetails.BadRequest)
+	require.Len(t, bad.GetFieldViolations(), 2)
+	require.Equal(t, "item.qty", bad.GetFieldViolations()[1].GetField())
+
+	require.NoError(t, runtime.FieldValidator.Validate(context.Background(), &binRequest{}))
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	req := &binRequest{err: fieldErr{field: "bin_id", reason: "required"}}
+
+	err := runtime.Validate(ctx, req)
+	require.Equal(t, runtime.CodeInvalidArgument, runtime.Convert(err).Code())
+
+	ctx = runtime.NewValidatorContext(ctx, runtime.FieldValidator)
+	err = runtime.Validate(ctx, req)
+	require.Len(t, runtime.Convert(err).Details(), 1)
+
+	require.NoError(t, runtime.Validate(context.Background(), struct{}{}))
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devil-dwj/go-wms/api/engine"
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/config"
+	"github.com/devil-dwj/go-wms/base/database/mysql"
+	"github.com/devil-dwj/go-wms/base/database/redis"
+	"github.com/devil-dwj/go-wms/base/log"
+	"github.com/devil-dwj/go-wms/base/mq/rabbitmq"
+	"github.com/devil-dwj/go-wms/base/otel"
+	red "github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 服务通用配置, 服务自己的配置嵌入它:
+//
+//	type Config struct {
+//		app.Config
+//		Stock StockConfig `json:"stock"`
+//	}
+type Config struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	// 配置后同时提供 gRPC
+	GrpcPort int `json:"grpc_port"`
+	// 日志文件, 默认 <name>.log
+	Log string `json:"log"`
+
+	// 以下组件未配置则不创建
+	Mysql    *mysql.Config `json:"mysql"`
+	Redis    *redis.Config `json:"redis"`
+	Otel     *otel.Config  `json:"otel"`
+	RabbitMQ string        `json:"rabbitmq"`
+}
+
+func (c *Config) AppConfig() *Config {
+	return c
+}
+
+// 嵌入了 Config 的服务配置
+type Configurer interface {
+	AppConfig() *Config
+}
+
+// 按顺序创建日志, tracing, 数据库, redis 和 Api, 退出时按相反顺序关闭
+type App struct {
+	conf *Config
+	opts appOptions
+
+	l     *zap.Logger
+	db    *gorm.DB
+	rdb   *red.Client
+	redis redis.Basic
+	api   *runtime.Api
+}
+
+// 读取配置文件到 v 并创建 App
+func MustLoad(path string, v Configurer, opt ...AppOption) *App {
+	config.MustLoad(path, v)
+
+	return MustNew(v.AppConfig(), opt...)
+}
+
+func MustNew(c *Config, opt ...AppOption) *App {
+	opts := appOptions{}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	if c.Name == "" {
+		panic("app: missing name")
+	}
+
+	a := &App{conf: c, opts: opts}
+
+	logname := c.Log
+	if logname == "" {
+		logname = c.Name + ".log"
+	}
+	a.l = log.MustLog(logname)
+
+	var stops []runtime.Hook
+	stops = append(stops, func(context.Context) error {
+		_ = a.l.Sync()
+		return nil
+	})
+
+	if c.Otel != nil {
+		oc := *c.Otel
+		if oc.Name == "" {
+			oc.Name = c.Name
+		}
+		tp := otel.MustInitProvider(oc)
+		stops = append(stops, tp.Shutdown)
+	}
+
+	if c.Mysql != nil {
+		a.db = mysql.NewDB(*c.Mysql, a.l)
+		stops = append(stops, func(context.Context) error {
+			return mysql.Close(a.db)
+		})
+	}
+
+	if c.Redis != nil {
+		a.rdb = redis.NewClient(*c.Redis)
+		a.redis = redis.NewBasicRedis(a.rdb)
+		stops = append(stops, runtime.CloseHook(a.rdb))
+	}
+
+	en := opts.engine
+	if en == nil {
+		en = engine.NewGinEngine(c.Port, a.l)
+	}
+	apiOpts := []runtime.ApiOption{
+		runtime.WithEngine(en),
+		runtime.WithTracing(c.Otel != nil),
+		runtime.WithServiceName(c.Name),
+	}
+	if a.redis != nil {
+		apiOpts = append(apiOpts, runtime.WithRedis(a.redis))
+	}
+	if c.GrpcPort != 0 {
+		apiOpts = append(apiOpts, runtime.WithTransport(engine.NewGrpcServer(c.GrpcPort, a.l)))
+	}
+	a.api = runtime.NewApi(a.l, append(apiOpts, opts.api...)...)
+	a.api.OnStop(stops...)
+
+	return a
+}
+
+func (a *App) Config() *Config {
+	return a.conf
+}
+
+func (a *App) Logger() *zap.Logger {
+	return a.l
+}
+
+// 未配置 mysql 时为 nil
+func (a *App) DB() *gorm.DB {
+	return a.db
+}
+
+// 未配置 redis 时为 nil
+func (a *App) Redis() redis.Basic {
+	return a.redis
+}
+
+func (a *App) RedisClient() *red.Client {
+	return a.rdb
+}
+
+// 注册路由, 如 pb.RegisterBinServiceRouter(app.Api(), srv)
+func (a *App) Api() *runtime.Api {
+	return a.api
+}
+
+// 创建生产者, 退出时关闭
+func (a *App) MustProducer(c *rabbitmq.ProducerConfig) *rabbitmq.Producer {
+	if a.conf.RabbitMQ == "" {
+		panic("app: missing rabbitmq config")
+	}
+
+	p := rabbitmq.NewProducer(a.conf.RabbitMQ, c)
+	a.api.OnStop(runtime.CloseHook(p))
+
+	return p
+}
+
+// 创建消费者, 退出时关闭
+func (a *App) MustConsumer(c *rabbitmq.ConsumerConfig) *rabbitmq.Consumer {
+	if a.conf.RabbitMQ == "" {
+		panic("app: missing rabbitmq config")
+	}
+
+	cs := rabbitmq.NewConsumer(a.conf.RabbitMQ, c)
+	a.api.OnStop(runtime.CloseHook(cs))
+
+	return cs
+}
+
+// 启动前执行, 如预热缓存
+func (a *App) OnStart(hooks ...runtime.Hook) {
+	a.api.OnStart(hooks...)
+}
+
+// 退出时执行, 在 App 创建的组件关闭之前
+func (a *App) OnStop(hooks ...runtime.Hook) {
+	a.api.OnStop(hooks...)
+}
+
+// 运行服务, 直到收到 SIGINT/SIGTERM, 然后关闭全部组件
+func (a *App) Run() error {
+	a.l.Info(fmt.Sprintf("start %s on :%d", a.conf.Name, a.conf.Port))
+
+	return a.api.Run()
+}
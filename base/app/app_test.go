@@ -0,0 +1,38 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/base/app"
+	"github.com/stretchr/testify/require"
+)
+
+type stockConfig struct {
+	app.Config
+	Warehouse string `json:"warehouse"`
+}
+
+func TestMustLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stock.json")
+	err := ioutil.WriteFile(path, []byte(`{
+		"name": "stock",
+		"port": 8080,
+		"log": "`+filepath.Join(dir, "stock.log")+`",
+		"warehouse": "WH-01"
+	}`), 0644)
+	require.NoError(t, err)
+
+	var c stockConfig
+	a := app.MustLoad(path, &c)
+
+	require.Equal(t, "WH-01", c.Warehouse)
+	require.Equal(t, 8080, a.Config().Port)
+	require.NotNil(t, a.Logger())
+	require.NotNil(t, a.Api())
+	// 未配置的组件不创建
+	require.Nil(t, a.DB())
+	require.Nil(t, a.Redis())
+}
@@ -0,0 +1,38 @@
+package app
+
+import "github.com/devil-dwj/go-wms/api/runtime"
+
+type appOptions struct {
+	engine runtime.Engine
+	api    []runtime.ApiOption
+}
+
+type AppOption interface {
+	apply(*appOptions)
+}
+
+type funcAppOption struct {
+	f func(*appOptions)
+}
+
+func (fao *funcAppOption) apply(ao *appOptions) {
+	fao.f(ao)
+}
+
+func newFuncAppOption(f func(*appOptions)) *funcAppOption {
+	return &funcAppOption{f: f}
+}
+
+// 替换默认的 GinEngine
+func WithEngine(en runtime.Engine) AppOption {
+	return newFuncAppOption(func(ao *appOptions) {
+		ao.engine = en
+	})
+}
+
+// 附加的 Api 选项, 如 WithLog, WithRecovery, WithValidator
+func WithApiOption(opts ...runtime.ApiOption) AppOption {
+	return newFuncAppOption(func(ao *appOptions) {
+		ao.api = append(ao.api, opts...)
+	})
+}
@@ -0,0 +1,94 @@
+package authz
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 角色及其权限. 权限如 bin.read, bin.* 匹配 bin 下所有权限, * 匹配所有
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// 授予主体的角色, Warehouse 为空时对所有仓库生效
+type Grant struct {
+	// token 的 sub
+	Subject   string `json:"subject"`
+	Role      string `json:"role"`
+	Warehouse string `json:"warehouse"`
+}
+
+// RBAC 模型, 可以嵌入服务配置或由 LoadDB 从数据库读取
+type Config struct {
+	Roles  []Role  `json:"roles"`
+	Grants []Grant `json:"grants"`
+}
+
+// 检查主体是否有权限, Reload 可以在运行时替换模型
+type Authorizer struct {
+	mu sync.RWMutex
+	// role -> permissions
+	roles map[string][]string
+	// subject -> grants
+	grants map[string][]Grant
+}
+
+func New(c *Config) (*Authorizer, error) {
+	a := &Authorizer{}
+	if err := a.Reload(c); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// 授权引用了未定义的角色时返回错误, 原模型不变
+func (a *Authorizer) Reload(c *Config) error {
+	roles := make(map[string][]string, len(c.Roles))
+	for _, r := range c.Roles {
+		roles[r.Name] = append(roles[r.Name], r.Permissions...)
+	}
+
+	grants := make(map[string][]Grant)
+	for _, g := range c.Grants {
+		if _, ok := roles[g.Role]; !ok {
+			return fmt.Errorf("authz: subject %s: unknown role %q", g.Subject, g.Role)
+		}
+		grants[g.Subject] = append(grants[g.Subject], g)
+	}
+
+	a.mu.Lock()
+	a.roles, a.grants = roles, grants
+	a.mu.Unlock()
+
+	return nil
+}
+
+// warehouse 为空时只有不限仓库的授权生效
+func (a *Authorizer) Allowed(subject, permission, warehouse string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, g := range a.grants[subject] {
+		if g.Warehouse != "" && g.Warehouse != warehouse {
+			continue
+		}
+		for _, p := range a.roles[g.Role] {
+			if match(p, permission) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func match(pattern, permission string) bool {
+	if pattern == "*" || pattern == permission {
+		return true
+	}
+
+	return strings.HasSuffix(pattern, ".*") && strings.HasPrefix(permission, pattern[:len(pattern)-1])
+}
@@ -0,0 +1,92 @@
+package authz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/authz"
+	"github.com/devil-dwj/go-wms/base/config"
+	"github.com/devil-dwj/go-wms/base/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+const model = `{
+	"roles": [
+		{"name": "admin", "permissions": ["*"]},
+		{"name": "picker", "permissions": ["bin.read", "pick.*"]}
+	],
+	"grants": [
+		{"subject": "u1", "role": "picker", "warehouse": "WH1"},
+		{"subject": "root", "role": "admin"}
+	]
+}`
+
+func TestAuthorizer(t *testing.T) {
+	var c authz.Config
+	require.NoError(t, config.LoadConfigJson([]byte(model), &c))
+	a, err := authz.New(&c)
+	require.NoError(t, err)
+
+	require.True(t, a.Allowed("u1", "bin.read", "WH1"))
+	require.True(t, a.Allowed("u1", "pick.create", "WH1"))
+	require.False(t, a.Allowed("u1", "bin.write", "WH1"))
+	require.False(t, a.Allowed("u1", "bin.read", "WH2"))
+	require.False(t, a.Allowed("u1", "bin.read", ""))
+	require.True(t, a.Allowed("root", "bin.write", "WH2"))
+	require.False(t, a.Allowed("u2", "bin.read", "WH1"))
+
+	c.Grants = append(c.Grants, authz.Grant{Subject: "u2", Role: "packer"})
+	require.Error(t, a.Reload(&c))
+	require.True(t, a.Allowed("u1", "bin.read", "WH1"))
+}
+
+func TestInterceptor(t *testing.T) {
+	var c authz.Config
+	require.NoError(t, config.LoadConfigJson([]byte(model), &c))
+	a, err := authz.New(&c)
+	require.NoError(t, err)
+
+	i := authz.Interceptor(a, authz.WithWarehouse(func(req interface{}) string {
+		return req.(string)
+	}))
+	call := func(ctx context.Context, permission, warehouse string) error {
+		_, err := i(ctx, warehouse, &runtime.MethodInfo{Permission: permission}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	ctx := token.NewClaimsContext(context.Background(), jwt.MapClaims{"sub": "u1"})
+
+	require.NoError(t, call(ctx, "bin.read", "WH1"))
+	require.NoError(t, call(context.Background(), "", "WH1"))
+
+	err = call(ctx, "bin.read", "WH2")
+	require.Equal(t, runtime.CodePermissionDenied, runtime.Convert(err).Code())
+
+	err = call(context.Background(), "bin.read", "WH1")
+	require.Equal(t, runtime.CodeUnauthenticated, runtime.Convert(err).Code())
+
+	// 自定义 claims 通过 GetSubject 读取主体
+	ctx = token.NewClaimsContext(context.Background(), &userClaims{StandardClaims: jwt.StandardClaims{Subject: "u1"}})
+	require.NoError(t, call(ctx, "bin.read", "WH1"))
+
+	// 无法读取主体是配置错误, 不是无权限
+	ctx = token.NewClaimsContext(context.Background(), &deviceClaims{StandardClaims: jwt.StandardClaims{Subject: "u1"}})
+	err = call(ctx, "bin.read", "WH1")
+	require.Equal(t, runtime.CodeInternal, runtime.Convert(err).Code())
+}
+
+type userClaims struct {
+	jwt.StandardClaims
+	Warehouse string `json:"wh"`
+}
+
+func (c *userClaims) GetSubject() string {
+	return c.Subject
+}
+
+type deviceClaims struct {
+	jwt.StandardClaims
+}
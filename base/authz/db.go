@@ -0,0 +1,57 @@
+package authz
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// 角色的一条权限
+type RolePermission struct {
+	Role       string `gorm:"column:role;primaryKey"`
+	Permission string `gorm:"column:permission;primaryKey"`
+}
+
+func (RolePermission) TableName() string {
+	return "authz_role_permission"
+}
+
+// 一条授权, 仓库为空串时不限仓库
+type RoleGrant struct {
+	Subject   string `gorm:"column:subject;primaryKey"`
+	Role      string `gorm:"column:role;primaryKey"`
+	Warehouse string `gorm:"column:warehouse;primaryKey"`
+}
+
+func (RoleGrant) TableName() string {
+	return "authz_grant"
+}
+
+// 从 authz_role_permission 和 authz_grant 读取模型, 用于 New 或 Reload
+func LoadDB(ctx context.Context, db *gorm.DB) (*Config, error) {
+	var perms []RolePermission
+	if err := db.WithContext(ctx).Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	var grants []RoleGrant
+	if err := db.WithContext(ctx).Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	index := make(map[string]int)
+	for _, p := range perms {
+		i, ok := index[p.Role]
+		if !ok {
+			i = len(c.Roles)
+			index[p.Role] = i
+			c.Roles = append(c.Roles, Role{Name: p.Role})
+		}
+		c.Roles[i].Permissions = append(c.Roles[i].Permissions, p.Permission)
+	}
+	for _, g := range grants {
+		c.Grants = append(c.Grants, Grant{Subject: g.Subject, Role: g.Role, Warehouse: g.Warehouse})
+	}
+
+	return c, nil
+}
@@ -0,0 +1,115 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/token"
+	"github.com/dgrijalva/jwt-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type options struct {
+	subject   func(jwt.Claims) string
+	warehouse func(req interface{}) string
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	f func(*options)
+}
+
+func (fo *funcOption) apply(o *options) {
+	fo.f(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{f: f}
+}
+
+// 从 claims 读取主体, 默认支持 jwt.MapClaims, *jwt.StandardClaims, *token.SessionClaims 的 sub,
+// 其它类型需实现 GetSubject() string, 否则在此指定
+func WithSubject(f func(jwt.Claims) string) Option {
+	return newFuncOption(func(o *options) {
+		o.subject = f
+	})
+}
+
+// 从请求读取仓库, 默认为请求消息的 warehouse_id 字段
+func WithWarehouse(f func(req interface{}) string) Option {
+	return newFuncOption(func(o *options) {
+		o.warehouse = f
+	})
+}
+
+// 授权拦截器, 检查 MethodInfo.Permission, 没有声明权限的方法不检查.
+// 需要在 token.Interceptor 之后注册, 没有 claims 时返回 Unauthenticated,
+// 无法读取主体时返回 Internal, 无权限时返回 PermissionDenied
+func Interceptor(a *Authorizer, opt ...Option) runtime.UnaryInterceptor {
+	opts := options{
+		subject:   subject,
+		warehouse: warehouseField("warehouse_id"),
+	}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	return func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+		if info.Permission == "" {
+			return next(ctx, req)
+		}
+
+		claims, ok := token.ClaimsFromContext(ctx)
+		if !ok {
+			return nil, runtime.Unauthenticated("missing token")
+		}
+		sub := opts.subject(claims)
+		if sub == "" {
+			return nil, runtime.Internal("no subject in claims %T", claims)
+		}
+		if !a.Allowed(sub, info.Permission, opts.warehouse(req)) {
+			return nil, runtime.PermissionDenied("permission %s denied", info.Permission)
+		}
+
+		return next(ctx, req)
+	}
+}
+
+func subject(claims jwt.Claims) string {
+	switch c := claims.(type) {
+	case jwt.MapClaims:
+		sub, _ := c["sub"].(string)
+		return sub
+	case *jwt.StandardClaims:
+		return c.Subject
+	case *token.SessionClaims:
+		return c.Subject
+	case interface{ GetSubject() string }:
+		return c.GetSubject()
+	}
+
+	return ""
+}
+
+// 请求消息的标量字段, 没有该字段或未设置时为空
+func warehouseField(name protoreflect.Name) func(req interface{}) string {
+	return func(req interface{}) string {
+		m, ok := req.(proto.Message)
+		if !ok {
+			return ""
+		}
+
+		msg := m.ProtoReflect()
+		fd := msg.Descriptor().Fields().ByName(name)
+		if fd == nil || fd.Cardinality() == protoreflect.Repeated || fd.Message() != nil || !msg.Has(fd) {
+			return ""
+		}
+
+		return fmt.Sprint(msg.Get(fd).Interface())
+	}
+}
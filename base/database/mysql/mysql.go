@@ -11,6 +11,16 @@ import (
 	gormlogger "gorm.io/gorm/logger"
 )
 
+type Config struct {
+	Dsn string `json:"dsn"`
+	// 慢查询阈值, 毫秒
+	SlowThreshold int `json:"slow_threshold"`
+}
+
+func NewDB(c Config, l *zap.Logger) *gorm.DB {
+	return GetDB(c.Dsn, l, time.Duration(c.SlowThreshold)*time.Millisecond)
+}
+
 func GetDB(dsn string, l *zap.Logger, holdTime time.Duration) *gorm.DB {
 	zl := gormLog(l, holdTime)
 
@@ -44,3 +54,13 @@ func gormLog(l *zap.Logger, holdTime time.Duration) gormlogger.Interface {
 
 	return zl
 }
+
+// 关闭连接池, 用于服务退出
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
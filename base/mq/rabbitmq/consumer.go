@@ -98,3 +98,9 @@ func (c *Consumer) Consumer() (<-chan amqp.Delivery, error) {
 		nil,
 	)
 }
+
+// 关闭通道和连接, 未确认的消息会重新入队
+func (c *Consumer) Close() error {
+	c.ch.Close()
+	return c.conn.Close()
+}
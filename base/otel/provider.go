@@ -9,11 +9,12 @@ import (
 )
 
 type Config struct {
-	Name string
-	Url  string
+	Name string `json:"name"`
+	Url  string `json:"url"`
 }
 
-func MustInitProvider(c Config) {
+// 返回的 TracerProvider 在退出时 Shutdown, 上报缓冲中的 span
+func MustInitProvider(c Config) *tracesdk.TracerProvider {
 	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(c.Url)))
 	if err != nil {
 		panic(err)
@@ -28,4 +29,6 @@ func MustInitProvider(c Config) {
 	)
 
 	otel.SetTracerProvider(tp)
+
+	return tp
 }
@@ -0,0 +1,13 @@
+package serializer
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+func ProtobufToBinary(message proto.Message) ([]byte, error) {
+	return proto.Marshal(message)
+}
+
+func BinaryToProtobufMessage(data []byte, message proto.Message) error {
+	return proto.Unmarshal(data, message)
+}
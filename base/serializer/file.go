@@ -22,7 +22,7 @@ func WriteProtobufToJSONFile(message proto.Message, filename string) error {
 }
 
 func WriteProtobufToBinaryFile(message proto.Message, filename string) error {
-	data, err := proto.Marshal(message)
+	data, err := ProtobufToBinary(message)
 	if err != nil {
 		return fmt.Errorf("cannot marshal proto message to binary: %w", err)
 	}
@@ -41,7 +41,7 @@ func ReadProtobufFromBinaryFile(filename string, message proto.Message) error {
 		return fmt.Errorf("cannot read binary data from file: %w", err)
 	}
 
-	err = proto.Unmarshal(data, message)
+	err = BinaryToProtobufMessage(data, message)
 	if err != nil {
 		return fmt.Errorf("cannot unmarshal binary to proto message: %w", err)
 	}
@@ -0,0 +1,139 @@
+package token
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/dgrijalva/jwt-go/request"
+	"google.golang.org/grpc/metadata"
+)
+
+type claimsKey struct{}
+
+func NewClaimsContext(ctx context.Context, claims jwt.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// 认证拦截器校验通过的 claims, 类型由 WithClaims 决定, 默认 jwt.MapClaims
+func ClaimsFromContext(ctx context.Context) (claims jwt.Claims, ok bool) {
+	claims, ok = ctx.Value(claimsKey{}).(jwt.Claims)
+	return
+}
+
+type authOptions struct {
+	claims    func() jwt.Claims
+	anonymous map[string]bool
+	extractor request.Extractor
+}
+
+type AuthOption interface {
+	apply(*authOptions)
+}
+
+type funcAuthOption struct {
+	f func(*authOptions)
+}
+
+func (fao *funcAuthOption) apply(ao *authOptions) {
+	fao.f(ao)
+}
+
+func newFuncAuthOption(f func(*authOptions)) *funcAuthOption {
+	return &funcAuthOption{f: f}
+}
+
+// 每次校验时创建 claims, 如 func() jwt.Claims { return new(UserClaims) }
+func WithClaims(f func() jwt.Claims) AuthOption {
+	return newFuncAuthOption(func(ao *authOptions) {
+		ao.claims = f
+	})
+}
+
+// token 的来源, 按顺序尝试, 默认 DefaultExtractor
+func WithExtractor(extractors ...request.Extractor) AuthOption {
+	return newFuncAuthOption(func(ao *authOptions) {
+		ao.extractor = extractor(extractors)
+	})
+}
+
+// 额外允许匿名访问的方法全名, 如 /wms.v1.UserService/Login.
+// 生成代码中 wms.api.method 的 anonymous 选项无需在此重复
+func WithAnonymous(methods ...string) AuthOption {
+	return newFuncAuthOption(func(ao *authOptions) {
+		for _, m := range methods {
+			ao.anonymous[m] = true
+		}
+	})
+}
+
+// 认证拦截器, 用 v 校验 WithExtractor 提取的 token 并把 claims 放入 context,
+// 失败返回 Unauthenticated. 匿名方法有合法 token 时同样放入 claims.
+// v 返回的 WarpError 原样返回, 如存储不可用时的 Unavailable, 匿名方法也不例外.
+// v 通常为 *KeySet 或检查撤销的 *Sessions, HS256 可用 NewKeySet(NewHMACKey("", secret)).
+// 用 runtime.WithUnaryInterceptor 全局注册, 或 WithInterceptor 按名称注册
+func Interceptor(v Verifier, opt ...AuthOption) runtime.UnaryInterceptor {
+	opts := authOptions{
+		claims:    func() jwt.Claims { return jwt.MapClaims{} },
+		anonymous: make(map[string]bool),
+		extractor: DefaultExtractor,
+	}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	return func(ctx context.Context, req interface{}, info *runtime.MethodInfo, next runtime.UnaryHandler) (interface{}, error) {
+		anonymous := info.Anonymous || opts.anonymous[info.FullMethod]
+
+		raw, ok := extract(ctx, opts.extractor)
+		if !ok {
+			if anonymous {
+				return next(ctx, req)
+			}
+			return nil, runtime.Unauthenticated("missing token")
+		}
+
+		claims := opts.claims()
+		if _, err := verify(ctx, v, raw, claims); err != nil {
+			if _, ok := runtime.FromError(err); ok {
+				return nil, err
+			}
+			if anonymous {
+				return next(ctx, req)
+			}
+			return nil, runtime.Unauthenticated("invalid token")
+		}
+
+		return next(NewClaimsContext(ctx, claims), req)
+	}
+}
+
+// 需要访问存储的 Verifier, 如 Sessions, 使用请求的 context
+func verify(ctx context.Context, v Verifier, raw string, claims jwt.Claims) (*jwt.Token, error) {
+	if cv, ok := v.(interface {
+		VerifyContext(ctx context.Context, token string, claims jwt.Claims) (*jwt.Token, error)
+	}); ok {
+		return cv.VerifyContext(ctx, raw, claims)
+	}
+
+	return v.Verify(raw, claims)
+}
+
+// 从 http 请求提取, gRPC 调用的元数据作为请求头
+func extract(ctx context.Context, e request.Extractor) (string, bool) {
+	r, ok := runtime.RequestFromContext(ctx)
+	if !ok {
+		md, _ := metadata.FromIncomingContext(ctx)
+		h := make(http.Header, len(md))
+		for k, v := range md {
+			h[textproto.CanonicalMIMEHeaderKey(k)] = v
+		}
+		r = &http.Request{Header: h, URL: &url.URL{}}
+	}
+
+	raw, err := e.ExtractToken(r)
+	return raw, err == nil && raw != ""
+}
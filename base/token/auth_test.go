@@ -0,0 +1,70 @@
+package token_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+const secret = "test-secret"
+
+func call(i runtime.UnaryInterceptor, ctx context.Context, info *runtime.MethodInfo) (jwt.Claims, error) {
+	var claims jwt.Claims
+	_, err := i(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		claims, _ = token.ClaimsFromContext(ctx)
+		return nil, nil
+	})
+
+	return claims, err
+}
+
+func requestContext(auth string) context.Context {
+	r := httptest.NewRequest("GET", "/v1/bins", nil)
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+
+	return runtime.NewRequestContext(context.Background(), r)
+}
+
+func TestInterceptor(t *testing.T) {
+	signed, err := token.Generate(&jwt.StandardClaims{Subject: "u1"}, secret)
+	require.NoError(t, err)
+
+	i := token.Interceptor(token.NewKeySet(token.NewHMACKey("", []byte(secret))),
+		token.WithClaims(func() jwt.Claims { return new(jwt.StandardClaims) }),
+		token.WithAnonymous("/wms.v1.UserService/Login"),
+	)
+	info := &runtime.MethodInfo{FullMethod: "/wms.v1.BinService/GetBin"}
+
+	claims, err := call(i, requestContext("Bearer "+signed), info)
+	require.NoError(t, err)
+	require.Equal(t, "u1", claims.(*jwt.StandardClaims).Subject)
+
+	// gRPC 从元数据读取
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+signed))
+	claims, err = call(i, ctx, info)
+	require.NoError(t, err)
+	require.Equal(t, "u1", claims.(*jwt.StandardClaims).Subject)
+
+	for _, auth := range []string{"", "Bearer " + signed + "x"} {
+		_, err = call(i, requestContext(auth), info)
+		require.Equal(t, runtime.CodeUnauthenticated, runtime.Convert(err).Code())
+	}
+
+	// 匿名方法: 来自 MethodDesc 或 WithAnonymous
+	for _, info := range []*runtime.MethodInfo{
+		{FullMethod: "/wms.v1.BinService/ListBins", Anonymous: true},
+		{FullMethod: "/wms.v1.UserService/Login"},
+	} {
+		claims, err = call(i, requestContext(""), info)
+		require.NoError(t, err)
+		require.Nil(t, claims)
+	}
+}
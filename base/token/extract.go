@@ -0,0 +1,79 @@
+package token
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go/request"
+)
+
+// 只有 Authorization: Bearer <token>, 未指定来源时使用
+var DefaultExtractor request.Extractor = request.AuthorizationHeaderExtractor
+
+// 按顺序尝试多个来源, 如
+//
+//	Extractors(FromHeader("Authorization"), FromHeader("AccessToken"), FromQuery("token"))
+func Extractors(extractors ...request.Extractor) request.Extractor {
+	return request.MultiExtractor(extractors)
+}
+
+// 请求头, Bearer 前缀可有可无
+func FromHeader(name string) request.Extractor {
+	return &request.PostExtractionFilter{
+		Extractor: request.HeaderExtractor{name},
+		Filter:    stripBearer,
+	}
+}
+
+// cookie
+func FromCookie(name string) request.Extractor {
+	return cookieExtractor(name)
+}
+
+// URL query 参数, 不读取请求体
+func FromQuery(name string) request.Extractor {
+	return queryExtractor(name)
+}
+
+func stripBearer(v string) (string, error) {
+	if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+		return v[7:], nil
+	}
+
+	return v, nil
+}
+
+type cookieExtractor string
+
+func (e cookieExtractor) ExtractToken(r *http.Request) (string, error) {
+	c, err := r.Cookie(string(e))
+	if err != nil || c.Value == "" {
+		return "", request.ErrNoTokenInRequest
+	}
+
+	return c.Value, nil
+}
+
+type queryExtractor string
+
+func (e queryExtractor) ExtractToken(r *http.Request) (string, error) {
+	if r.URL == nil {
+		return "", request.ErrNoTokenInRequest
+	}
+	if v := r.URL.Query().Get(string(e)); v != "" {
+		return v, nil
+	}
+
+	return "", request.ErrNoTokenInRequest
+}
+
+func extractor(extractors []request.Extractor) request.Extractor {
+	switch len(extractors) {
+	case 0:
+		return DefaultExtractor
+	case 1:
+		return extractors[0]
+	}
+
+	return Extractors(extractors...)
+}
@@ -0,0 +1,68 @@
+package token_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestExtractors(t *testing.T) {
+	e := token.Extractors(
+		token.FromHeader("Authorization"),
+		token.FromHeader("AccessToken"),
+		token.FromCookie("token"),
+		token.FromQuery("token"),
+	)
+
+	for name, r := range map[string]*http.Request{
+		"bearer": func() *http.Request {
+			r := httptest.NewRequest("GET", "/v1/bins", nil)
+			r.Header.Set("Authorization", "Bearer t1")
+			return r
+		}(),
+		"header": func() *http.Request {
+			r := httptest.NewRequest("GET", "/v1/bins", nil)
+			r.Header.Set("AccessToken", "t1")
+			return r
+		}(),
+		"cookie": func() *http.Request {
+			r := httptest.NewRequest("GET", "/v1/bins", nil)
+			r.AddCookie(&http.Cookie{Name: "token", Value: "t1"})
+			return r
+		}(),
+		"query": httptest.NewRequest("GET", "/v1/bins?token=t1", nil),
+	} {
+		raw, err := token.ExtractTokenFromRequest(r, e)
+		require.NoError(t, err, name)
+		require.Equal(t, "t1", raw, name)
+	}
+
+	// 默认只有 Authorization: Bearer
+	_, err := token.ExtractTokenFromRequest(httptest.NewRequest("GET", "/v1/bins?token=t1", nil))
+	require.Error(t, err)
+}
+
+func TestInterceptorExtractor(t *testing.T) {
+	s := token.NewKeySet(token.NewHMACKey("", []byte(secret)))
+	signed, err := s.Sign(&jwt.StandardClaims{Subject: "u1"})
+	require.NoError(t, err)
+
+	i := token.Interceptor(s, token.WithExtractor(token.FromHeader("AccessToken"), token.FromQuery("token")))
+	info := &runtime.MethodInfo{FullMethod: "/wms.v1.BinService/GetBin"}
+
+	r := httptest.NewRequest("GET", "/v1/bins?token="+signed, nil)
+	_, err = call(i, runtime.NewRequestContext(context.Background(), r), info)
+	require.NoError(t, err)
+
+	// gRPC 元数据作为请求头
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accesstoken", signed))
+	_, err = call(i, ctx, info)
+	require.NoError(t, err)
+}
@@ -0,0 +1,210 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 公开 JWKS 的路径, 用 runtime.WithHTTPHandler(JWKSPath, JWKSHandler(keys)) 挂载
+const JWKSPath = "/.well-known/jwks.json"
+
+// RFC 7517 公钥, 只包含 RSA, EC 和 OKP 需要的字段
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC 和 OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// 公钥集合, HMAC 密钥不公开
+func (s *KeySet) JWKS() *JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := &JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		if j, ok := publicJWK(k); ok {
+			set.Keys = append(set.Keys, j)
+		}
+	}
+
+	return set
+}
+
+// 提供 KeySet 的 JWKS, 每次请求重新生成, 轮换后立即生效
+func JWKSHandler(s *KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := json.Marshal(s.JWKS())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write(data)
+	})
+}
+
+// 解析 JWKS 为只能校验的 KeySet, 加密用途和不支持的密钥类型被忽略
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("token: %w", err)
+	}
+
+	s := NewKeySet()
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		k, err := j.key()
+		if err != nil {
+			return nil, fmt.Errorf("token: jwk %q: %w", j.Kid, err)
+		}
+		if k != nil {
+			s.keys = append(s.keys, k)
+		}
+	}
+
+	return s, nil
+}
+
+// 读取本地 JWKS 文件
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+func publicJWK(k *Key) (JWK, bool) {
+	j := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		j.Kty = "RSA"
+		j.N = encode(pub.N.Bytes())
+		j.E = encode(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		j.Kty = "EC"
+		j.Crv = pub.Curve.Params().Name
+		j.X = encode(pub.X.FillBytes(make([]byte, size)))
+		j.Y = encode(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		j.Kty = "OKP"
+		j.Crv = "Ed25519"
+		j.X = encode(pub)
+	default:
+		return JWK{}, false
+	}
+
+	return j, true
+}
+
+// 不支持的类型返回 nil
+func (j JWK) key() (*Key, error) {
+	k := &Key{ID: j.Kid}
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return nil, err
+		}
+		k.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		k.Method = jwt.SigningMethodRS256
+	case "EC":
+		curve, ok := map[string]elliptic.Curve{
+			"P-256": elliptic.P256(),
+			"P-384": elliptic.P384(),
+			"P-521": elliptic.P521(),
+		}[j.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point not on curve %s", j.Crv)
+		}
+		k.Public = pub
+		k.Method, _ = ecdsaMethod(curve)
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		k.Public = ed25519.PublicKey(x)
+		k.Method = SigningMethodEdDSA
+	default:
+		return nil, nil
+	}
+
+	// alg 可省略, 否则必须与密钥类型一致
+	if j.Alg != "" && j.Alg != k.Method.Alg() {
+		m := jwt.GetSigningMethod(j.Alg)
+		if m == nil || j.Kty != "RSA" {
+			return nil, fmt.Errorf("alg %q does not match key type %s", j.Alg, j.Kty)
+		}
+		// RSA 密钥可用于 RS384, RS512
+		if _, ok := m.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("alg %q does not match key type %s", j.Alg, j.Kty)
+		}
+		k.Method = m
+	}
+
+	return k, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
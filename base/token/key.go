@@ -0,0 +1,226 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrMissingKey = errors.New("missing signing key")
+	ErrUnknownKey = errors.New("unknown key id")
+)
+
+// 签名或校验密钥
+type Key struct {
+	// kid, 签名时写入 token 头, 校验时按它查找
+	ID     string
+	Method jwt.SigningMethod
+	// 签名用, 从 JWKS 读取的密钥为 nil, 只能校验
+	Private interface{}
+	// 校验用, HMAC 与 Private 相同
+	Public interface{}
+}
+
+// HS256, 密钥不会出现在 JWKS 中
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+}
+
+// RS256
+func NewRSAKey(id string, priv *rsa.PrivateKey) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodRS256, Private: priv, Public: &priv.PublicKey}
+}
+
+// 按曲线选择 ES256, ES384 或 ES512
+func NewECDSAKey(id string, priv *ecdsa.PrivateKey) (*Key, error) {
+	m, err := ecdsaMethod(priv.Curve)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: id, Method: m, Private: priv, Public: &priv.PublicKey}, nil
+}
+
+// EdDSA
+func NewEd25519Key(id string, priv ed25519.PrivateKey) *Key {
+	return &Key{ID: id, Method: SigningMethodEdDSA, Private: priv, Public: priv.Public()}
+}
+
+func ecdsaMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	}
+
+	return nil, fmt.Errorf("token: unsupported curve %s", curve.Params().Name)
+}
+
+// 解析 PEM 私钥: PKCS#8, PKCS#1 RSA 或 SEC 1 EC
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("token: invalid PEM key")
+	}
+
+	var priv interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("token: %w", err)
+	}
+
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, k), nil
+	case *ecdsa.PrivateKey:
+		return NewECDSAKey(id, k)
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, k), nil
+	}
+
+	return nil, fmt.Errorf("token: unsupported key type %T", priv)
+}
+
+// 读取 PEM 私钥文件
+func LoadKey(id string, path string) (*Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKey(id, data)
+}
+
+// 校验 token, 认证拦截器使用
+type Verifier interface {
+	Verify(token string, claims jwt.Claims) (*jwt.Token, error)
+}
+
+// 可轮换的密钥集合, 第一个有私钥的密钥用于签名, 所有密钥都用于校验
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+func NewKeySet(keys ...*Key) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+// 新密钥放到最前用于签名, 旧密钥保留到已签发的 token 过期后再 Remove
+func (s *KeySet) Rotate(k *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*Key, 0, len(s.keys)+1)
+	keys = append(keys, k)
+	for _, old := range s.keys {
+		if old.ID != k.ID {
+			keys = append(keys, old)
+		}
+	}
+	s.keys = keys
+}
+
+func (s *KeySet) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		if k.ID != id {
+			keys = append(keys, k)
+		}
+	}
+	s.keys = keys
+}
+
+func (s *KeySet) Key(id string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+
+	return nil, false
+}
+
+// 当前的签名密钥
+func (s *KeySet) signingKey() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.Private != nil {
+			return k, nil
+		}
+	}
+
+	return nil, ErrMissingKey
+}
+
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	k, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	t := jwt.NewWithClaims(k.Method, claims)
+	if k.ID != "" {
+		t.Header["kid"] = k.ID
+	}
+
+	return t.SignedString(k.Private)
+}
+
+// 按 kid 选择密钥, 算法必须与密钥一致, 防止用公钥作为 HMAC 密钥伪造.
+// Sessions 签发的 refresh token 只能用于刷新, 返回 ErrInvalidToken
+func (s *KeySet) Verify(token string, claims jwt.Claims) (*jwt.Token, error) {
+	t, err := s.verify(token, claims)
+	if err != nil {
+		return nil, err
+	}
+	if isRefresh(token) {
+		return nil, ErrInvalidToken
+	}
+
+	return t, nil
+}
+
+func (s *KeySet) verify(token string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		id, _ := t.Header["kid"].(string)
+		k, ok := s.Key(id)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if t.Method.Alg() != k.Method.Alg() {
+			return nil, ErrSigningMethod
+		}
+
+		return k.Public, nil
+	})
+}
@@ -0,0 +1,116 @@
+package token_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devil-dwj/go-wms/base/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+func keys(t *testing.T) []*token.Key {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ec, err := token.NewECDSAKey("ec", ecKey)
+	require.NoError(t, err)
+
+	return []*token.Key{token.NewRSAKey("rsa", rsaKey), ec, token.NewEd25519Key("ed", edKey)}
+}
+
+func TestKeySet(t *testing.T) {
+	for _, k := range keys(t) {
+		t.Run(k.Method.Alg(), func(t *testing.T) {
+			s := token.NewKeySet(k)
+			signed, err := s.Sign(&jwt.StandardClaims{Subject: "u1"})
+			require.NoError(t, err)
+
+			claims := new(jwt.StandardClaims)
+			parsed, err := s.Verify(signed, claims)
+			require.NoError(t, err)
+			require.Equal(t, k.ID, parsed.Header["kid"])
+			require.Equal(t, "u1", claims.Subject)
+
+			// 发布的 JWKS 可以离线校验
+			data, err := json.Marshal(s.JWKS())
+			require.NoError(t, err)
+			public, err := token.ParseJWKS(data)
+			require.NoError(t, err)
+			_, err = public.Verify(signed, new(jwt.StandardClaims))
+			require.NoError(t, err)
+			_, err = public.Sign(&jwt.StandardClaims{})
+			require.Equal(t, token.ErrMissingKey, err)
+		})
+	}
+}
+
+func TestKeySetRotate(t *testing.T) {
+	ks := keys(t)
+	s := token.NewKeySet(ks[0], token.NewHMACKey("hmac", []byte("secret")))
+	old, err := s.Sign(&jwt.StandardClaims{})
+	require.NoError(t, err)
+
+	s.Rotate(ks[2])
+	signed, err := s.Sign(&jwt.StandardClaims{})
+	require.NoError(t, err)
+	parsed, err := s.Verify(signed, new(jwt.StandardClaims))
+	require.NoError(t, err)
+	require.Equal(t, "ed", parsed.Header["kid"])
+
+	// 旧密钥签发的 token 在移除前仍然有效
+	_, err = s.Verify(old, new(jwt.StandardClaims))
+	require.NoError(t, err)
+	s.Remove("rsa")
+	_, err = s.Verify(old, new(jwt.StandardClaims))
+	require.Error(t, err)
+
+	// HMAC 密钥不公开
+	w := httptest.NewRecorder()
+	token.JWKSHandler(s).ServeHTTP(w, httptest.NewRequest("GET", token.JWKSPath, nil))
+	var set token.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, "OKP", set.Keys[0].Kty)
+}
+
+func TestVerifyAlgorithm(t *testing.T) {
+	ks := keys(t)
+	s := token.NewKeySet(ks[0])
+
+	// 用 RSA 公钥作为 HMAC 密钥伪造的 token
+	der, err := x509.MarshalPKIXPublicKey(ks[0].Public)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{})
+	forged.Header["kid"] = "rsa"
+	signed, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	_, err = s.Verify(signed, new(jwt.StandardClaims))
+	require.Error(t, err)
+
+	_, err = token.Generate(&jwt.StandardClaims{}, "")
+	require.Equal(t, token.ErrMissingKey, err)
+}
+
+func TestParseKey(t *testing.T) {
+	for _, k := range keys(t) {
+		der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+		require.NoError(t, err)
+
+		parsed, err := token.ParseKey(k.ID, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		require.NoError(t, err)
+		require.Equal(t, k.Method.Alg(), parsed.Method.Alg())
+	}
+}
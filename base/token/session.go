@@ -0,0 +1,383 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/database/redis"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrTokenRevoked = errors.New("token revoked")
+	// refresh token 被重复使用, 整个会话已被撤销
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+	// refresh token 的 aud, 通过 JWKS 校验的其它服务据此拒绝
+	RefreshAudience = "refresh"
+)
+
+// Sessions 签发的 token 的 claims
+type SessionClaims struct {
+	jwt.StandardClaims
+	// 会话 id, 同一次登录签发和刷新的 token 相同
+	Session string `json:"sid"`
+	// TypeAccess 或 TypeRefresh
+	Type string `json:"typ"`
+}
+
+// 登录或刷新返回的 token 对
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// access token 过期时间, unix 秒
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+type sessionOptions struct {
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	grace      time.Duration
+	prefix     string
+}
+
+type SessionOption interface {
+	apply(*sessionOptions)
+}
+
+type funcSessionOption struct {
+	f func(*sessionOptions)
+}
+
+func (fso *funcSessionOption) apply(so *sessionOptions) {
+	fso.f(so)
+}
+
+func newFuncSessionOption(f func(*sessionOptions)) *funcSessionOption {
+	return &funcSessionOption{f: f}
+}
+
+// access token 有效期, 默认 15 分钟
+func WithAccessTTL(d time.Duration) SessionOption {
+	return newFuncSessionOption(func(so *sessionOptions) {
+		so.accessTTL = d
+	})
+}
+
+// refresh token 和会话的有效期, 每次刷新后重新计算, 默认 7 天
+func WithRefreshTTL(d time.Duration) SessionOption {
+	return newFuncSessionOption(func(so *sessionOptions) {
+		so.refreshTTL = d
+	})
+}
+
+// refresh token 使用后仍可再次刷新的时间, 默认 10 秒, 0 为不允许.
+// 手持终端超时重试时, 同一 refresh token 的并发刷新不会被当作盗用
+func WithRefreshGrace(d time.Duration) SessionOption {
+	return newFuncSessionOption(func(so *sessionOptions) {
+		so.grace = d
+	})
+}
+
+// redis key 前缀, 默认 token:
+func WithKeyPrefix(prefix string) SessionOption {
+	return newFuncSessionOption(func(so *sessionOptions) {
+		so.prefix = prefix
+	})
+}
+
+// 有状态的会话: 签发 access/refresh token 对, 刷新时轮换 refresh token,
+// 撤销的 jti 和会话记录在 redis 中, 过期后自动删除.
+// redis 出错时返回 runtime.Unavailable, 不会当作 token 无效.
+//
+//	token:session:<sid>  会话存在时其 token 才有效
+//	token:refresh:<jti>  未使用的 refresh token, 使用一次即删除
+//	token:rotated:<jti>  已使用的 refresh token, TTL 为 WithRefreshGrace
+//	token:revoked:<jti>  撤销的 token, TTL 为其剩余有效期
+type Sessions struct {
+	keys *KeySet
+	r    redis.Basic
+	opts sessionOptions
+}
+
+func NewSessions(keys *KeySet, r redis.Basic, opt ...SessionOption) *Sessions {
+	opts := sessionOptions{
+		accessTTL:  15 * time.Minute,
+		refreshTTL: 7 * 24 * time.Hour,
+		grace:      10 * time.Second,
+		prefix:     "token:",
+	}
+	for _, o := range opt {
+		o.apply(&opts)
+	}
+
+	return &Sessions{keys: keys, r: r, opts: opts}
+}
+
+func (s *Sessions) sessionKey(sid string) string {
+	return s.opts.prefix + "session:" + sid
+}
+
+func (s *Sessions) refreshKey(jti string) string {
+	return s.opts.prefix + "refresh:" + jti
+}
+
+func (s *Sessions) rotatedKey(jti string) string {
+	return s.opts.prefix + "rotated:" + jti
+}
+
+func (s *Sessions) revokedKey(jti string) string {
+	return s.opts.prefix + "revoked:" + jti
+}
+
+func storeError(err error) error {
+	return runtime.Unavailable("token store: %s", err)
+}
+
+// 登录, 为 subject 创建新会话
+func (s *Sessions) Issue(ctx context.Context, subject string) (*TokenPair, error) {
+	sid, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	pair, refreshID, err := s.issue(subject, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.r.Set(ctx, s.sessionKey(sid), subject, s.opts.refreshTTL); err != nil {
+		return nil, storeError(err)
+	}
+	if err := s.store(ctx, refreshID, sid); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// 签发 token 对, 返回 refresh token 的 jti
+func (s *Sessions) issue(subject, sid string) (*TokenPair, string, error) {
+	accessID, err := newID()
+	if err != nil {
+		return nil, "", err
+	}
+	refreshID, err := newID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	access := &SessionClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        accessID,
+			Subject:   subject,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.opts.accessTTL).Unix(),
+		},
+		Session: sid,
+		Type:    TypeAccess,
+	}
+	refresh := &SessionClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        refreshID,
+			Audience:  RefreshAudience,
+			Subject:   subject,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.opts.refreshTTL).Unix(),
+		},
+		Session: sid,
+		Type:    TypeRefresh,
+	}
+
+	pair := &TokenPair{ExpiresAt: access.ExpiresAt}
+	if pair.AccessToken, err = s.keys.Sign(access); err != nil {
+		return nil, "", err
+	}
+	if pair.RefreshToken, err = s.keys.Sign(refresh); err != nil {
+		return nil, "", err
+	}
+
+	return pair, refreshID, nil
+}
+
+// 记录未使用的 refresh token
+func (s *Sessions) store(ctx context.Context, refreshID, sid string) error {
+	if _, err := s.r.Set(ctx, s.refreshKey(refreshID), sid, s.opts.refreshTTL); err != nil {
+		return storeError(err)
+	}
+
+	return nil
+}
+
+// 用 refresh token 换新的 token 对, 旧 refresh token 作废.
+// 已使用过的 refresh token 在 WithRefreshGrace 之后再次出现说明被盗用, 撤销整个会话.
+// 宽限期内的重复刷新各自得到新的 token 对, 同属原会话
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims := new(SessionClaims)
+	if _, err := s.keys.verify(refreshToken, claims); err != nil {
+		return nil, err
+	}
+	if claims.Type != TypeRefresh {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.r.Exists(ctx, s.revokedKey(claims.Id))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	// 删除成功的调用才是首次使用, 其余调用只在宽限期内有效
+	fresh, err := s.r.Del(ctx, s.refreshKey(claims.Id))
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	alive, err := s.r.Exists(ctx, s.sessionKey(claims.Session))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if !alive {
+		return nil, ErrTokenRevoked
+	}
+
+	switch {
+	case fresh && s.opts.grace > 0:
+		if _, err := s.r.Set(ctx, s.rotatedKey(claims.Id), claims.Session, s.opts.grace); err != nil {
+			return nil, storeError(err)
+		}
+	case !fresh:
+		retry := false
+		if s.opts.grace > 0 {
+			if retry, err = s.r.Exists(ctx, s.rotatedKey(claims.Id)); err != nil {
+				return nil, storeError(err)
+			}
+		}
+		if !retry {
+			if err := s.RevokeSession(ctx, claims.Session); err != nil {
+				return nil, err
+			}
+			return nil, ErrTokenReused
+		}
+	}
+
+	pair, refreshID, err := s.issue(claims.Subject, claims.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只延长仍然存在的会话, 与 RevokeSession 并发时不会恢复已撤销的会话
+	alive, err = s.r.ExpireAt(ctx, s.sessionKey(claims.Session), time.Now().Add(s.opts.refreshTTL))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if !alive {
+		return nil, ErrTokenRevoked
+	}
+	if err := s.store(ctx, refreshID, claims.Session); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// 撤销单个 token, 已过期的 token 无需撤销
+func (s *Sessions) Revoke(ctx context.Context, token string) error {
+	claims := new(SessionClaims)
+	if _, err := s.keys.verify(token, claims); err != nil {
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil
+		}
+		return err
+	}
+
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return nil
+	}
+	if claims.Type == TypeRefresh {
+		if _, err := s.r.Del(ctx, s.refreshKey(claims.Id)); err != nil {
+			return storeError(err)
+		}
+	}
+	if _, err := s.r.Set(ctx, s.revokedKey(claims.Id), claims.Session, ttl); err != nil {
+		return storeError(err)
+	}
+
+	return nil
+}
+
+// 强制下线, 会话的所有 token 立即失效
+func (s *Sessions) RevokeSession(ctx context.Context, sid string) error {
+	if _, err := s.r.Del(ctx, s.sessionKey(sid)); err != nil {
+		return storeError(err)
+	}
+
+	return nil
+}
+
+// 校验 access token 并检查撤销, 认证拦截器优先调用它.
+// claims 可以是任意类型, 会话信息另外解析
+func (s *Sessions) VerifyContext(ctx context.Context, token string, claims jwt.Claims) (*jwt.Token, error) {
+	t, err := s.keys.Verify(token, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// 签名已校验
+	session := new(SessionClaims)
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, session); err != nil {
+		return nil, err
+	}
+	if session.Type != TypeAccess {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.r.Exists(ctx, s.revokedKey(session.Id))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	alive, err := s.r.Exists(ctx, s.sessionKey(session.Session))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if revoked || !alive {
+		return nil, ErrTokenRevoked
+	}
+
+	return t, nil
+}
+
+func (s *Sessions) Verify(token string, claims jwt.Claims) (*jwt.Token, error) {
+	return s.VerifyContext(context.Background(), token, claims)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sessions 签发的 refresh token, 签名须已校验
+func isRefresh(token string) bool {
+	claims := new(SessionClaims)
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return false
+	}
+
+	return claims.Type == TypeRefresh || claims.Audience == RefreshAudience
+}
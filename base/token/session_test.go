@@ -0,0 +1,248 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devil-dwj/go-wms/api/runtime"
+	"github.com/devil-dwj/go-wms/base/database/redis"
+	"github.com/devil-dwj/go-wms/base/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+// 只实现 Sessions 用到的方法
+type memRedis struct {
+	redis.Basic
+	mu   sync.Mutex
+	keys map[string]time.Time
+	// 不为空时所有操作返回该错误
+	err error
+	// 不为空时在 Set 之前调用, 用于插入并发的操作
+	beforeSet func(key string)
+}
+
+func newMemRedis() *memRedis {
+	return &memRedis{keys: make(map[string]time.Time)}
+}
+
+func (r *memRedis) Set(ctx context.Context, key string, value string, t time.Duration) (bool, error) {
+	if r.beforeSet != nil {
+		r.beforeSet(key)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false, r.err
+	}
+	r.keys[key] = time.Now().Add(t)
+	return true, nil
+}
+
+func (r *memRedis) Exists(ctx context.Context, keys ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false, r.err
+	}
+	exp, ok := r.keys[keys[0]]
+	return ok && time.Now().Before(exp), nil
+}
+
+func (r *memRedis) ExpireAt(ctx context.Context, key string, tm time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false, r.err
+	}
+	exp, ok := r.keys[key]
+	if !ok || !time.Now().Before(exp) {
+		return false, nil
+	}
+	r.keys[key] = tm
+	return true, nil
+}
+
+func (r *memRedis) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *memRedis) Del(ctx context.Context, keys ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false, r.err
+	}
+	_, ok := r.keys[keys[0]]
+	delete(r.keys, keys[0])
+	return ok, nil
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	ks := token.NewKeySet(keys(t)[2])
+	s := token.NewSessions(ks, newMemRedis(), token.WithRefreshGrace(0))
+
+	pair, err := s.Issue(ctx, "u1")
+	require.NoError(t, err)
+
+	claims := new(token.SessionClaims)
+	_, err = s.Verify(pair.AccessToken, claims)
+	require.NoError(t, err)
+	require.Equal(t, "u1", claims.Subject)
+
+	// refresh token 不能作为 access token, 只校验签名的 KeySet 和拦截器也拒绝
+	_, err = s.Verify(pair.RefreshToken, new(token.SessionClaims))
+	require.Equal(t, token.ErrInvalidToken, err)
+	_, err = ks.Verify(pair.RefreshToken, new(token.SessionClaims))
+	require.Equal(t, token.ErrInvalidToken, err)
+	info := &runtime.MethodInfo{FullMethod: "/wms.v1.BinService/GetBin"}
+	_, err = call(token.Interceptor(ks), requestContext("Bearer "+pair.RefreshToken), info)
+	require.Equal(t, runtime.CodeUnauthenticated, runtime.Convert(err).Code())
+	_, err = call(token.Interceptor(ks), requestContext("Bearer "+pair.AccessToken), info)
+	require.NoError(t, err)
+
+	next, err := s.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	_, err = s.Verify(next.AccessToken, new(token.SessionClaims))
+	require.NoError(t, err)
+
+	// 重复使用旧 refresh token, 整个会话被撤销
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.Equal(t, token.ErrTokenReused, err)
+	_, err = s.Verify(next.AccessToken, new(token.SessionClaims))
+	require.Equal(t, token.ErrTokenRevoked, err)
+	_, err = s.Refresh(ctx, next.RefreshToken)
+	require.Equal(t, token.ErrTokenRevoked, err)
+}
+
+func TestSessionsRevoke(t *testing.T) {
+	ctx := context.Background()
+	s := token.NewSessions(token.NewKeySet(keys(t)[2]), newMemRedis())
+
+	pair, err := s.Issue(ctx, "u1")
+	require.NoError(t, err)
+	other, err := s.Issue(ctx, "u1")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Revoke(ctx, pair.AccessToken))
+	_, err = s.Verify(pair.AccessToken, new(token.SessionClaims))
+	require.Equal(t, token.ErrTokenRevoked, err)
+
+	// 撤销的 refresh token 不算重复使用, 其它会话不受影响
+	require.NoError(t, s.Revoke(ctx, pair.RefreshToken))
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.Equal(t, token.ErrTokenRevoked, err)
+	_, err = s.Verify(other.AccessToken, new(token.SessionClaims))
+	require.NoError(t, err)
+
+	// 认证拦截器检查撤销
+	i := token.Interceptor(s, token.WithClaims(func() jwt.Claims { return new(token.SessionClaims) }))
+	info := &runtime.MethodInfo{FullMethod: "/wms.v1.BinService/GetBin"}
+	_, err = call(i, requestContext("Bearer "+other.AccessToken), info)
+	require.NoError(t, err)
+
+	claims := new(token.SessionClaims)
+	_, err = s.Verify(other.AccessToken, claims)
+	require.NoError(t, err)
+	require.NoError(t, s.RevokeSession(ctx, claims.Session))
+	_, err = call(i, requestContext("Bearer "+other.AccessToken), info)
+	require.Equal(t, runtime.CodeUnauthenticated, runtime.Convert(err).Code())
+}
+
+func TestSessionsRefreshGrace(t *testing.T) {
+	ctx := context.Background()
+	s := token.NewSessions(token.NewKeySet(keys(t)[2]), newMemRedis(), token.WithRefreshGrace(100*time.Millisecond))
+
+	pair, err := s.Issue(ctx, "u1")
+	require.NoError(t, err)
+
+	// 超时重试: 同一 refresh token 并发刷新都成功, 会话不受影响
+	pairs := make([]*token.TokenPair, 4)
+	errs := make([]error, len(pairs))
+	var wg sync.WaitGroup
+	for i := range pairs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pairs[i], errs[i] = s.Refresh(ctx, pair.RefreshToken)
+		}(i)
+	}
+	wg.Wait()
+	for i, p := range pairs {
+		require.NoError(t, errs[i])
+		_, err = s.Verify(p.AccessToken, new(token.SessionClaims))
+		require.NoError(t, err)
+	}
+
+	// 宽限期过后再次使用视为盗用
+	time.Sleep(150 * time.Millisecond)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.Equal(t, token.ErrTokenReused, err)
+	_, err = s.Verify(pairs[0].AccessToken, new(token.SessionClaims))
+	require.Equal(t, token.ErrTokenRevoked, err)
+}
+
+func TestSessionsUnavailable(t *testing.T) {
+	ctx := context.Background()
+	r := newMemRedis()
+	s := token.NewSessions(token.NewKeySet(keys(t)[2]), r)
+
+	pair, err := s.Issue(ctx, "u1")
+	require.NoError(t, err)
+
+	r.fail(errors.New("dial tcp: connection refused"))
+	_, err = s.Verify(pair.AccessToken, new(token.SessionClaims))
+	require.Equal(t, runtime.CodeUnavailable, runtime.Convert(err).Code())
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.Equal(t, runtime.CodeUnavailable, runtime.Convert(err).Code())
+
+	// 拦截器不把存储故障当作 token 无效, 匿名方法也返回错误
+	i := token.Interceptor(s, token.WithClaims(func() jwt.Claims { return new(token.SessionClaims) }))
+	for _, info := range []*runtime.MethodInfo{
+		{FullMethod: "/wms.v1.BinService/GetBin"},
+		{FullMethod: "/wms.v1.BinService/ListBins", Anonymous: true},
+	} {
+		_, err = call(i, requestContext("Bearer "+pair.AccessToken), info)
+		require.Equal(t, runtime.CodeUnavailable, runtime.Convert(err).Code(), info.FullMethod)
+	}
+
+	// 恢复后会话仍然有效
+	r.fail(nil)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+}
+
+// 刷新过程中会话被撤销, 刷新失败且会话不会恢复
+func TestSessionsRefreshRevoked(t *testing.T) {
+	ctx := context.Background()
+	r := newMemRedis()
+	s := token.NewSessions(token.NewKeySet(keys(t)[2]), r)
+
+	pair, err := s.Issue(ctx, "u1")
+	require.NoError(t, err)
+	claims := new(token.SessionClaims)
+	_, err = s.Verify(pair.AccessToken, claims)
+	require.NoError(t, err)
+
+	// 刷新已通过会话检查, 记录已使用的 refresh token 时撤销会话
+	r.beforeSet = func(key string) {
+		if strings.HasPrefix(key, "token:rotated:") {
+			require.NoError(t, s.RevokeSession(ctx, claims.Session))
+		}
+	}
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.Equal(t, token.ErrTokenRevoked, err)
+
+	r.beforeSet = nil
+	_, err = s.Verify(pair.AccessToken, new(token.SessionClaims))
+	require.Equal(t, token.ErrTokenRevoked, err)
+	_, err = s.Refresh(ctx, pair.RefreshToken)
+	require.Equal(t, token.ErrTokenRevoked, err)
+}
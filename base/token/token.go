@@ -13,19 +13,19 @@ var (
 	ErrSigningMethod = errors.New("signing method err")
 )
 
-const defaultSecretKey = "go-wms-token"
-
+// HS256 签名, 非对称密钥和 kid 使用 KeySet
 func Generate(claims jwt.Claims, secret string) (string, error) {
 	if secret == "" {
-		secret = defaultSecretKey
+		return "", ErrMissingKey
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString([]byte(secret))
 }
 
-func ExtractTokenFromRequest(r *http.Request) (string, error) {
-	token, err := request.AuthorizationHeaderExtractor.ExtractToken(r)
+// 未指定 extractors 时使用 DefaultExtractor
+func ExtractTokenFromRequest(r *http.Request, extractors ...request.Extractor) (string, error) {
+	token, err := extractor(extractors).ExtractToken(r)
 	if err != nil {
 		return "", errors.New("ExtractTokenFromRequestFailed")
 	}
@@ -33,8 +33,8 @@ func ExtractTokenFromRequest(r *http.Request) (string, error) {
 	return token, nil
 }
 
-func VerityExtractTokenFromRequest(r *http.Request, claims jwt.Claims, secret string) (*jwt.Token, error) {
-	token, err := request.AuthorizationHeaderExtractor.ExtractToken(r)
+func VerityExtractTokenFromRequest(r *http.Request, claims jwt.Claims, secret string, extractors ...request.Extractor) (*jwt.Token, error) {
+	token, err := extractor(extractors).ExtractToken(r)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +42,11 @@ func VerityExtractTokenFromRequest(r *http.Request, claims jwt.Claims, secret st
 	return Verify(token, claims, secret)
 }
 
+// 只接受 HMAC, 非对称密钥使用 KeySet.Verify
 func Verify(token string, claims jwt.Claims, secret string) (*jwt.Token, error) {
+	if secret == "" {
+		return nil, ErrMissingKey
+	}
 	verifyToken, err := jwt.ParseWithClaims(
 		token,
 		claims,
@@ -66,16 +70,8 @@ func Verify(token string, claims jwt.Claims, secret string) (*jwt.Token, error)
 func TokenMapClaims(token string, secret string) map[string]interface{} {
 	parMap := make(map[string]interface{})
 
-	parseAuth, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
-		// return []byte("My Secret"), nil
-		return []byte(secret), nil
-	})
-	if err != nil {
-		return parMap
-	}
-
-	claim, ok := parseAuth.Claims.(jwt.MapClaims)
-	if !ok {
+	claim := jwt.MapClaims{}
+	if _, err := Verify(token, claim, secret); err != nil {
 		return parMap
 	}
 
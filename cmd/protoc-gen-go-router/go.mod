@@ -3,6 +3,7 @@ module github.com/devil-dwj/go-wms/cmd/protoc-gen-go-router
 go 1.16
 
 require (
+	github.com/stretchr/testify v1.7.1
 	google.golang.org/genproto v0.0.0-20220308174144-ae0e22291548
 	google.golang.org/protobuf v1.27.1
 )
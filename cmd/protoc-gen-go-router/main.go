@@ -1,19 +1,31 @@
 package main
 
 import (
+	"flag"
+
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/types/pluginpb"
 )
 
 func main() {
+	var flags flag.FlagSet
+	openapi := flags.Bool("openapi", false, "每个服务另外生成 OpenAPI 3 文档 <file>.<Service>.openapi.json")
+	flags.StringVar(&openapiOpts.json, "openapi_json", "builtin", "与服务端编码一致: builtin (JSONBuiltin) 或 protojson (JSONPb)")
+	flags.StringVar(&openapiOpts.envelope, "openapi_envelope", "legacy", "与服务端信封一致: legacy 或 raw")
+	flags.StringVar(&openapiOpts.version, "openapi_version", "1.0.0", "文档的 info.version")
 
-	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
 		for _, f := range gen.Files {
 			gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
 			if !f.Generate {
 				continue
 			}
 			generateFile(gen, f)
+			if *openapi {
+				if err := generateOpenAPI(gen, f); err != nil {
+					return err
+				}
+			}
 		}
 		return nil
 	})
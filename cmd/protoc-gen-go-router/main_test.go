@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func field(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(num),
+		Type:   typ.Enum(),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+
+	return f
+}
+
+func method(name, in, out string, rule *annotations.HttpRule, unknown []byte) *descriptorpb.MethodDescriptorProto {
+	opts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(opts, annotations.E_Http, rule)
+	opts.ProtoReflect().SetUnknown(unknown)
+
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String(name),
+		InputType:  proto.String(in),
+		OutputType: proto.String(out),
+		Options:    opts,
+	}
+}
+
+// wms/v1/bin.proto:
+//
+//	enum BinState { BIN_STATE_UNSPECIFIED = 0; BIN_STATE_READY = 1; }
+//	message Item { string sku = 1; int64 qty = 2; }
+//	message GetBinRequest { int32 warehouse_id = 1; string bin_id = 2; Item item = 3; BinState state = 4; google.protobuf.Timestamp at = 5; }
+//	message Bin { string bin_id = 1; Item item = 2; BinState state = 3; }
+//
+//	service BinService {
+//	  rpc GetBin(GetBinRequest) returns (Bin) { get: "/v1/warehouses/{warehouse_id}/bins/{bin_id}" }
+//	  rpc UpdateBin(GetBinRequest) returns (Bin) { put: "/v1/{bin_id=bins/*}" body: "item" response_body: "item" }
+//	}
+func binFile(unknown []byte) *descriptorpb.FileDescriptorProto {
+	const (
+		str  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i32  = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		i64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		msg  = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+		enum = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+	)
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("wms/v1/bin.proto"),
+		Package:    proto.String("wms.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/api/annotations.proto", "google/protobuf/timestamp.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/wms/v1;wmsv1")},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("BinState"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("BIN_STATE_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("BIN_STATE_READY"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Item"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("sku", 1, str, ""),
+				field("qty", 2, i64, ""),
+			}},
+			{Name: proto.String("GetBinRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("warehouse_id", 1, i32, ""),
+				field("bin_id", 2, str, ""),
+				field("item", 3, msg, ".wms.v1.Item"),
+				field("state", 4, enum, ".wms.v1.BinState"),
+				field("at", 5, msg, ".google.protobuf.Timestamp"),
+			}},
+			{Name: proto.String("Bin"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("bin_id", 1, str, ""),
+				field("item", 2, msg, ".wms.v1.Item"),
+				field("state", 3, enum, ".wms.v1.BinState"),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("BinService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBin", ".wms.v1.GetBinRequest", ".wms.v1.Bin",
+					&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/warehouses/{warehouse_id}/bins/{bin_id}"}}, unknown),
+				method("UpdateBin", ".wms.v1.GetBinRequest", ".wms.v1.Bin",
+					&annotations.HttpRule{Pattern: &annotations.HttpRule_Put{Put: "/v1/{bin_id=bins/*}"}, Body: "item", ResponseBody: "item"}, nil),
+			},
+		}},
+	}
+}
+
+// 由 binFile 生成插件, 与 protoc 传入的请求一致
+func newPlugin(t *testing.T, file *descriptorpb.FileDescriptorProto) *protogen.Plugin {
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+			protodesc.ToFileDescriptorProto(annotations.File_google_api_http_proto),
+			protodesc.ToFileDescriptorProto(annotations.File_google_api_annotations_proto),
+			protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+			file,
+		},
+	}
+	gen, err := protogen.Options{}.New(req)
+	require.NoError(t, err)
+
+	return gen
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// openapi_* 参数
+type openapiOptions struct {
+	json     string
+	envelope string
+	version  string
+}
+
+var openapiOpts openapiOptions
+
+// OpenAPI 3 文档, 只包含用到的部分
+type document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       info                 `json:"info"`
+	Tags       []tag                `json:"tags,omitempty"`
+	Paths      map[string]*pathItem `json:"paths"`
+	Components components           `json:"components"`
+}
+
+type info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type pathItem struct {
+	Get    *operation `json:"get,omitempty"`
+	Put    *operation `json:"put,omitempty"`
+	Post   *operation `json:"post,omitempty"`
+	Delete *operation `json:"delete,omitempty"`
+	Patch  *operation `json:"patch,omitempty"`
+}
+
+type operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type components struct {
+	Schemas map[string]*schema `json:"schemas"`
+}
+
+type schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	// *schema 或 true
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Nullable             bool        `json:"nullable,omitempty"`
+	AllOf                []*schema   `json:"allOf,omitempty"`
+}
+
+const (
+	jsonContentType        = "application/json"
+	eventStreamContentType = "text/event-stream"
+)
+
+// 每个有 http 规则的服务生成一个文档
+func generateOpenAPI(gen *protogen.Plugin, file *protogen.File) error {
+	switch {
+	case openapiOpts.json != "builtin" && openapiOpts.json != "protojson":
+		return fmt.Errorf("openapi_json: unknown value %q", openapiOpts.json)
+	case openapiOpts.envelope != "legacy" && openapiOpts.envelope != "raw":
+		return fmt.Errorf("openapi_envelope: unknown value %q", openapiOpts.envelope)
+	}
+
+	for _, service := range file.Services {
+		doc := newOpenAPIBuilder().build(service)
+		if len(doc.Paths) == 0 {
+			continue
+		}
+
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"."+service.GoName+".openapi.json", "")
+		if _, err := g.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type openapiBuilder struct {
+	doc *document
+	// 正在展开为 query 参数的消息, 防止递归
+	visiting map[protoreflect.FullName]bool
+}
+
+func newOpenAPIBuilder() *openapiBuilder {
+	return &openapiBuilder{
+		doc: &document{
+			OpenAPI:    "3.0.3",
+			Paths:      make(map[string]*pathItem),
+			Components: components{Schemas: make(map[string]*schema)},
+		},
+		visiting: make(map[protoreflect.FullName]bool),
+	}
+}
+
+func (b *openapiBuilder) build(service *protogen.Service) *document {
+	name := string(service.Desc.FullName())
+	b.doc.Info = info{
+		Title:       name,
+		Description: comments(service.Comments.Leading),
+		Version:     openapiOpts.version,
+	}
+	b.doc.Tags = []tag{{Name: name, Description: comments(service.Comments.Leading)}}
+
+	for _, method := range service.Methods {
+		rule := getHttpRule(method)
+		if rule.method == "" {
+			continue
+		}
+
+		path := openapiPath(rule.path)
+		item, ok := b.doc.Paths[path]
+		if !ok {
+			item = &pathItem{}
+			b.doc.Paths[path] = item
+		}
+
+		op := b.operation(method, rule)
+		switch rule.method {
+		case "GET":
+			item.Get = op
+		case "PUT":
+			item.Put = op
+		case "POST":
+			item.Post = op
+		case "DELETE":
+			item.Delete = op
+		case "PATCH":
+			item.Patch = op
+		}
+	}
+
+	return b.doc
+}
+
+func (b *openapiBuilder) operation(method *protogen.Method, rule httpRule) *operation {
+	desc := comments(method.Comments.Leading)
+	op := &operation{
+		Tags:        []string{string(method.Parent.Desc.FullName())},
+		Summary:     strings.SplitN(desc, "\n", 2)[0],
+		OperationID: method.Parent.GoName + "_" + method.GoName,
+		Responses:   make(map[string]*response),
+	}
+	if strings.Contains(desc, "\n") {
+		op.Description = desc
+	}
+
+	// 路径参数
+	var skip []string
+	for _, v := range templateVariables(rule.path) {
+		skip = append(skip, v.fieldPath)
+		f := findField(method.Input, v.fieldPath)
+		if f == nil {
+			continue
+		}
+		p := &parameter{
+			Name:        v.fieldPath,
+			In:          "path",
+			Description: comments(f.Comments.Leading),
+			Required:    true,
+			Schema:      b.querySchema(f),
+		}
+		if v.pattern != "" {
+			p.Description = strings.TrimSpace(p.Description + "\n格式 " + v.pattern)
+		}
+		op.Parameters = append(op.Parameters, p)
+	}
+
+	// 请求体和 query 参数
+	switch rule.body {
+	case "*":
+		op.RequestBody = jsonBody(b.messageSchema(method.Input))
+	case "":
+		op.Parameters = append(op.Parameters, b.queryParams(method.Input, "", "", skip)...)
+	default:
+		if f := findField(method.Input, rule.body); f != nil {
+			op.RequestBody = jsonBody(b.fieldSchema(f))
+		}
+		op.Parameters = append(op.Parameters, b.queryParams(method.Input, "", "", append(skip, rule.body))...)
+	}
+
+	// 回复
+	data := b.messageSchema(method.Output)
+	if rule.responseBody != "" {
+		if f := findField(method.Output, rule.responseBody); f != nil {
+			data = b.fieldSchema(f)
+		}
+	}
+	b.responses(op, data)
+
+	// 服务端流不加信封, 错误同普通回复
+	if method.Desc.IsStreamingServer() {
+		op.Responses["200"] = &response{
+			Description: "SSE 流, 每个 data 事件为一条消息, 出错时以 error 事件结束",
+			Content:     map[string]mediaType{eventStreamContentType: {Schema: data}},
+		}
+	}
+
+	return op
+}
+
+// 回复按信封包装
+func (b *openapiBuilder) responses(op *operation, data *schema) {
+	if openapiOpts.envelope == "raw" {
+		op.Responses["200"] = jsonResponse("成功", data)
+		op.Responses["default"] = jsonResponse("失败", b.statusSchema())
+		return
+	}
+
+	op.Responses["200"] = jsonResponse("code 为 0 时成功, 否则为业务错误码, data 为空串", &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			"code": {Type: "integer", Format: "int32"},
+			"msg":  {Type: "string"},
+			"data": data,
+		},
+	})
+	op.Responses["default"] = jsonResponse("失败, code 为错误码", b.errorSchema())
+}
+
+// LegacyEnvelope 的错误
+func (b *openapiBuilder) errorSchema() *schema {
+	const name = "wms.api.Error"
+	if _, ok := b.doc.Components.Schemas[name]; !ok {
+		b.doc.Components.Schemas[name] = &schema{
+			Type: "object",
+			Properties: map[string]*schema{
+				"code": {Type: "integer", Format: "int32"},
+				"msg":  {Type: "string"},
+				"data": {Type: "string"},
+			},
+		}
+	}
+
+	return ref(name)
+}
+
+// RawEnvelope 的错误, google.rpc.Status
+func (b *openapiBuilder) statusSchema() *schema {
+	const name = "google.rpc.Status"
+	if _, ok := b.doc.Components.Schemas[name]; !ok {
+		b.doc.Components.Schemas[name] = &schema{
+			Type: "object",
+			Properties: map[string]*schema{
+				"code":    {Type: "integer", Format: "int32"},
+				"message": {Type: "string"},
+				"details": {Type: "array", Items: anySchema()},
+			},
+		}
+	}
+
+	return ref(name)
+}
+
+// 除路径变量和 body 外的字段, 嵌套消息展开为 a.b, repeated 消息和 map 不支持
+func (b *openapiBuilder) queryParams(msg *protogen.Message, prefix, namePrefix string, skip []string) []*parameter {
+	b.visiting[msg.Desc.FullName()] = true
+	defer delete(b.visiting, msg.Desc.FullName())
+
+	var params []*parameter
+	for _, f := range msg.Fields {
+		key := prefix + string(f.Desc.Name())
+		name := namePrefix + jsonName(f)
+		if skipped(key, skip) || f.Desc.IsMap() {
+			continue
+		}
+
+		if f.Message != nil && !queryWellKnown(f.Message.Desc) {
+			if f.Desc.IsList() || b.visiting[f.Message.Desc.FullName()] {
+				continue
+			}
+			params = append(params, b.queryParams(f.Message, key+".", name+".", skip)...)
+			continue
+		}
+
+		params = append(params, &parameter{
+			Name:        name,
+			In:          "query",
+			Description: comments(f.Comments.Leading),
+			Schema:      b.querySchema(f),
+		})
+	}
+
+	return params
+}
+
+func skipped(key string, skip []string) bool {
+	for _, s := range skip {
+		if key == s || strings.HasPrefix(key, s+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 路径和 query 参数, well-known types 为字符串
+func (b *openapiBuilder) querySchema(f *protogen.Field) *schema {
+	s := b.elementSchema(f)
+	if f.Message != nil && queryWellKnown(f.Message.Desc) {
+		s = wellKnownSchema(f.Message)
+	}
+	if f.Desc.IsList() {
+		return &schema{Type: "array", Items: s}
+	}
+
+	return s
+}
+
+func (b *openapiBuilder) fieldSchema(f *protogen.Field) *schema {
+	switch {
+	case f.Desc.IsMap():
+		return &schema{Type: "object", AdditionalProperties: b.elementSchema(f.Message.Fields[1])}
+	case f.Desc.IsList():
+		return &schema{Type: "array", Items: b.elementSchema(f)}
+	}
+
+	return b.elementSchema(f)
+}
+
+// 单个值的类型, 与服务端 JSON 编码一致
+func (b *openapiBuilder) elementSchema(f *protogen.Field) *schema {
+	switch f.Desc.Kind() {
+	case protoreflect.EnumKind:
+		return b.enumSchema(f.Enum)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.messageSchema(f.Message)
+	}
+
+	return scalarSchema(f.Desc)
+}
+
+func scalarSchema(fd protoreflect.FieldDescriptor) *schema {
+	protojson := openapiOpts.json == "protojson"
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &schema{Type: "boolean"}
+	case protoreflect.BytesKind:
+		return &schema{Type: "string", Format: "byte"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &schema{Type: "integer", Format: "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if protojson {
+			return &schema{Type: "string", Format: "int64"}
+		}
+		return &schema{Type: "integer", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if protojson {
+			return &schema{Type: "string", Format: "uint64"}
+		}
+		return &schema{Type: "integer", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &schema{Type: "number", Format: "double"}
+	}
+
+	return &schema{Type: "string"}
+}
+
+// 枚举放在 components 中, protojson 为名字, encoding/json 为数字
+func (b *openapiBuilder) enumSchema(e *protogen.Enum) *schema {
+	name := string(e.Desc.FullName())
+	if _, ok := b.doc.Components.Schemas[name]; ok {
+		return ref(name)
+	}
+
+	s := &schema{Description: comments(e.Comments.Leading)}
+	var values []string
+	for _, v := range e.Values {
+		if openapiOpts.json == "protojson" {
+			s.Enum = append(s.Enum, string(v.Desc.Name()))
+		} else {
+			s.Enum = append(s.Enum, v.Desc.Number())
+			values = append(values, fmt.Sprintf("%d - %s", v.Desc.Number(), v.Desc.Name()))
+		}
+	}
+	if openapiOpts.json == "protojson" {
+		s.Type = "string"
+	} else {
+		s.Type, s.Format = "integer", "int32"
+		s.Description = strings.TrimSpace(s.Description + "\n" + strings.Join(values, "\n"))
+	}
+	b.doc.Components.Schemas[name] = s
+
+	return ref(name)
+}
+
+// 消息放在 components 中, protojson 时 well-known types 内联
+func (b *openapiBuilder) messageSchema(m *protogen.Message) *schema {
+	if openapiOpts.json == "protojson" {
+		if s := protojsonWellKnownSchema(m); s != nil {
+			return s
+		}
+	}
+
+	name := string(m.Desc.FullName())
+	if _, ok := b.doc.Components.Schemas[name]; ok {
+		return ref(name)
+	}
+
+	s := &schema{
+		Type:        "object",
+		Description: comments(m.Comments.Leading),
+		Properties:  make(map[string]*schema),
+	}
+	// 先占位, 递归的消息引用自身
+	b.doc.Components.Schemas[name] = s
+
+	for _, f := range m.Fields {
+		// encoding/json 将 oneof 编码为 {"Oneof": {"Field": ...}}
+		if o := f.Oneof; o != nil && !o.Desc.IsSynthetic() && openapiOpts.json != "protojson" {
+			os, ok := s.Properties[o.GoName]
+			if !ok {
+				os = &schema{
+					Type:        "object",
+					Description: strings.TrimSpace(comments(o.Comments.Leading) + "\noneof, 只能设置一个字段"),
+					Properties:  make(map[string]*schema),
+				}
+				s.Properties[o.GoName] = os
+			}
+			os.Properties[f.GoName] = b.describe(b.fieldSchema(f), f)
+			continue
+		}
+
+		s.Properties[jsonName(f)] = b.describe(b.fieldSchema(f), f)
+	}
+
+	return ref(name)
+}
+
+// 字段注释, $ref 不能带其它属性, 用 allOf 包一层
+func (b *openapiBuilder) describe(s *schema, f *protogen.Field) *schema {
+	desc := comments(f.Comments.Leading)
+	if desc == "" {
+		return s
+	}
+	if s.Ref != "" {
+		return &schema{Description: desc, AllOf: []*schema{s}}
+	}
+
+	c := *s
+	c.Description = strings.TrimSpace(desc + "\n" + s.Description)
+	return &c
+}
+
+// query 中可用字符串表示的 well-known types, 与 runtime.PopulateQueryParameters 一致
+func queryWellKnown(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return true
+	}
+
+	return isWrapper(md)
+}
+
+func isWrapper(md protoreflect.MessageDescriptor) bool {
+	if md.ParentFile().Package() != "google.protobuf" || !strings.HasSuffix(string(md.Name()), "Value") {
+		return false
+	}
+
+	return md.Fields().Len() == 1 && md.Fields().Get(0).Name() == "value"
+}
+
+func wellKnownSchema(m *protogen.Message) *schema {
+	switch m.Desc.FullName() {
+	case "google.protobuf.Timestamp":
+		return &schema{Type: "string", Format: "date-time"}
+	case "google.protobuf.Duration":
+		return &schema{Type: "string", Description: "如 1.5s"}
+	case "google.protobuf.FieldMask":
+		return &schema{Type: "string", Description: "逗号分隔的字段路径"}
+	}
+
+	if isWrapper(m.Desc) {
+		s := scalarSchema(m.Fields[0].Desc)
+		s.Nullable = true
+		return s
+	}
+
+	return nil
+}
+
+// proto3 JSON 规范中特殊编码的 well-known types
+func protojsonWellKnownSchema(m *protogen.Message) *schema {
+	if s := wellKnownSchema(m); s != nil {
+		return s
+	}
+
+	switch m.Desc.FullName() {
+	case "google.protobuf.Struct", "google.protobuf.Empty":
+		return &schema{Type: "object", AdditionalProperties: true}
+	case "google.protobuf.Value":
+		return &schema{}
+	case "google.protobuf.ListValue":
+		return &schema{Type: "array", Items: &schema{}}
+	case "google.protobuf.Any":
+		return anySchema()
+	}
+
+	return nil
+}
+
+func anySchema() *schema {
+	return &schema{
+		Type:                 "object",
+		Properties:           map[string]*schema{"@type": {Type: "string"}},
+		AdditionalProperties: true,
+	}
+}
+
+func ref(name string) *schema {
+	return &schema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonBody(s *schema) *requestBody {
+	return &requestBody{
+		Required: true,
+		Content:  map[string]mediaType{jsonContentType: {Schema: s}},
+	}
+}
+
+func jsonResponse(desc string, s *schema) *response {
+	return &response{
+		Description: desc,
+		Content:     map[string]mediaType{jsonContentType: {Schema: s}},
+	}
+}
+
+// protojson 使用 json_name, encoding/json 使用 json tag 即原始名
+func jsonName(f *protogen.Field) string {
+	if openapiOpts.json == "protojson" {
+		return f.Desc.JSONName()
+	}
+
+	return string(f.Desc.Name())
+}
+
+// 去掉注释每行开头的空格
+func comments(c protogen.Comments) string {
+	lines := strings.Split(strings.TrimSpace(string(c)), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(l, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// 按 a.b.c 形式的字段路径查找字段
+func findField(msg *protogen.Message, fieldPath string) *protogen.Field {
+	var field *protogen.Field
+	for _, name := range strings.Split(fieldPath, ".") {
+		if msg == nil {
+			return nil
+		}
+		field = nil
+		for _, f := range msg.Fields {
+			if string(f.Desc.Name()) == name {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return nil
+		}
+		msg = field.Message
+	}
+
+	return field
+}
+
+var variablePattern = regexp.MustCompile(`\{([^}=]+)(?:=([^}]*))?\}`)
+
+type templateVariable struct {
+	fieldPath string
+	// {name=shelves/*} 中的 shelves/*
+	pattern string
+}
+
+func templateVariables(path string) []templateVariable {
+	var vars []templateVariable
+	for _, m := range variablePattern.FindAllStringSubmatch(path, -1) {
+		vars = append(vars, templateVariable{fieldPath: m[1], pattern: m[2]})
+	}
+
+	return vars
+}
+
+// {name=shelves/*} 转为 {name}
+func openapiPath(path string) string {
+	return variablePattern.ReplaceAllString(path, "{$1}")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 生成 BinService 的文档, 返回解码后的 JSON
+func openapiDocument(t *testing.T, opts openapiOptions) map[string]interface{} {
+	saved := openapiOpts
+	openapiOpts = opts
+	defer func() { openapiOpts = saved }()
+
+	gen := newPlugin(t, binFile(nil))
+	require.NoError(t, generateOpenAPI(gen, gen.Files[len(gen.Files)-1]))
+
+	resp := gen.Response()
+	require.Empty(t, resp.GetError())
+	require.Len(t, resp.File, 1)
+	require.Equal(t, "example.com/wms/v1/bin.BinService.openapi.json", resp.File[0].GetName())
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(resp.File[0].GetContent()), &doc))
+
+	return doc
+}
+
+func TestOpenAPI(t *testing.T) {
+	cases := []struct {
+		opts openapiOptions
+		// GetBin 和 UpdateBin 的路径参数和 query 参数
+		params    []string
+		putParams []string
+		item      string
+		state     string
+		// 回复和错误
+		reply string
+		error string
+	}{
+		{
+			opts: openapiOptions{json: "builtin", envelope: "legacy", version: "1.0.0"},
+			params: []string{
+				`{"name":"warehouse_id","in":"path","required":true,"schema":{"type":"integer","format":"int32"}}`,
+				`{"name":"bin_id","in":"path","required":true,"schema":{"type":"string"}}`,
+				`{"name":"item.sku","in":"query","schema":{"type":"string"}}`,
+				`{"name":"item.qty","in":"query","schema":{"type":"integer","format":"int64"}}`,
+				`{"name":"state","in":"query","schema":{"$ref":"#/components/schemas/wms.v1.BinState"}}`,
+				`{"name":"at","in":"query","schema":{"type":"string","format":"date-time"}}`,
+			},
+			putParams: []string{
+				`{"name":"bin_id","in":"path","required":true,"description":"格式 bins/*","schema":{"type":"string"}}`,
+				`{"name":"warehouse_id","in":"query","schema":{"type":"integer","format":"int32"}}`,
+				`{"name":"state","in":"query","schema":{"$ref":"#/components/schemas/wms.v1.BinState"}}`,
+				`{"name":"at","in":"query","schema":{"type":"string","format":"date-time"}}`,
+			},
+			item:  `{"type":"object","properties":{"sku":{"type":"string"},"qty":{"type":"integer","format":"int64"}}}`,
+			state: `{"type":"integer","format":"int32","enum":[0,1],"description":"0 - BIN_STATE_UNSPECIFIED\n1 - BIN_STATE_READY"}`,
+			reply: `{"type":"object","properties":{"code":{"type":"integer","format":"int32"},"msg":{"type":"string"},"data":{"$ref":"#/components/schemas/wms.v1.Bin"}}}`,
+			error: `{"$ref":"#/components/schemas/wms.api.Error"}`,
+		},
+		{
+			opts: openapiOptions{json: "protojson", envelope: "raw", version: "1.0.0"},
+			params: []string{
+				`{"name":"warehouse_id","in":"path","required":true,"schema":{"type":"integer","format":"int32"}}`,
+				`{"name":"bin_id","in":"path","required":true,"schema":{"type":"string"}}`,
+				`{"name":"item.sku","in":"query","schema":{"type":"string"}}`,
+				`{"name":"item.qty","in":"query","schema":{"type":"string","format":"int64"}}`,
+				`{"name":"state","in":"query","schema":{"$ref":"#/components/schemas/wms.v1.BinState"}}`,
+				`{"name":"at","in":"query","schema":{"type":"string","format":"date-time"}}`,
+			},
+			putParams: []string{
+				`{"name":"bin_id","in":"path","required":true,"description":"格式 bins/*","schema":{"type":"string"}}`,
+				`{"name":"warehouseId","in":"query","schema":{"type":"integer","format":"int32"}}`,
+				`{"name":"state","in":"query","schema":{"$ref":"#/components/schemas/wms.v1.BinState"}}`,
+				`{"name":"at","in":"query","schema":{"type":"string","format":"date-time"}}`,
+			},
+			item:  `{"type":"object","properties":{"sku":{"type":"string"},"qty":{"type":"string","format":"int64"}}}`,
+			state: `{"type":"string","enum":["BIN_STATE_UNSPECIFIED","BIN_STATE_READY"]}`,
+			reply: `{"$ref":"#/components/schemas/wms.v1.Bin"}`,
+			error: `{"$ref":"#/components/schemas/google.rpc.Status"}`,
+		},
+	}
+	for _, c := range cases {
+		doc := openapiDocument(t, c.opts)
+		mode := c.opts.json
+
+		paths := doc["paths"].(map[string]interface{})
+		require.Len(t, paths, 2, mode)
+
+		get := paths["/v1/warehouses/{warehouse_id}/bins/{bin_id}"].(map[string]interface{})["get"].(map[string]interface{})
+		require.Equal(t, "BinService_GetBin", get["operationId"], mode)
+		params := get["parameters"].([]interface{})
+		require.Len(t, params, len(c.params), mode)
+		for i, p := range c.params {
+			require.JSONEq(t, p, marshal(t, params[i]), mode)
+		}
+
+		responses := get["responses"].(map[string]interface{})
+		require.JSONEq(t, c.reply, marshal(t, responses["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"]), mode)
+		require.JSONEq(t, c.error, marshal(t, responses["default"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"]), mode)
+
+		// {bin_id=bins/*} 的格式写在描述中, body 和 response_body 只取 item, 其余字段为 query
+		put := paths["/v1/{bin_id}"].(map[string]interface{})["put"].(map[string]interface{})
+		params = put["parameters"].([]interface{})
+		require.Len(t, params, len(c.putParams), mode)
+		for i, p := range c.putParams {
+			require.JSONEq(t, p, marshal(t, params[i]), mode)
+		}
+		require.JSONEq(t, `{"required":true,"content":{"application/json":{"schema":{"$ref":"#/components/schemas/wms.v1.Item"}}}}`, marshal(t, put["requestBody"]), mode)
+
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		require.JSONEq(t, c.item, marshal(t, schemas["wms.v1.Item"]), mode)
+		require.JSONEq(t, c.state, marshal(t, schemas["wms.v1.BinState"]), mode)
+	}
+}
+
+func TestOpenAPIInvalidOptions(t *testing.T) {
+	saved := openapiOpts
+	defer func() { openapiOpts = saved }()
+
+	gen := newPlugin(t, binFile(nil))
+	openapiOpts = openapiOptions{json: "yaml", envelope: "legacy"}
+	require.EqualError(t, generateOpenAPI(gen, gen.Files[len(gen.Files)-1]), `openapi_json: unknown value "yaml"`)
+	openapiOpts = openapiOptions{json: "builtin", envelope: "grpc"}
+	require.EqualError(t, generateOpenAPI(gen, gen.Files[len(gen.Files)-1]), `openapi_envelope: unknown value "grpc"`)
+}
+
+func marshal(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return string(b)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// (wms.api.method) 的字段号, 见 api/annotations/annotations.proto
+const (
+	methodRuleField      = 50100
+	methodRuleMiddleware = 1
+	methodRuleAnonymous  = 2
+	methodRulePermission = 3
+)
+
+// wms.api.MethodRule
+type methodRule struct {
+	middleware []string
+	anonymous  bool
+	permission string
+}
+
+// 插件不依赖 go-wms 模块, 扩展未注册, 从未知字段中解析
+func getMethodRule(method *protogen.Method) methodRule {
+	var rule methodRule
+	if method.Desc.Options() == nil {
+		return rule
+	}
+
+	b := method.Desc.Options().(proto.Message).ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return rule
+		}
+		b = b[n:]
+
+		if num != methodRuleField || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return rule
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return rule
+		}
+		b = b[n:]
+		// 同一选项可能分多段出现, 按 proto 规则合并
+		rule.merge(v)
+	}
+
+	return rule
+}
+
+func (r *methodRule) merge(b []byte) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+
+		if num == methodRuleMiddleware && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return
+			}
+			b = b[n:]
+			r.middleware = append(r.middleware, v)
+			continue
+		}
+		if num == methodRuleAnonymous && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return
+			}
+			b = b[n:]
+			r.anonymous = protowire.DecodeBool(v)
+			continue
+		}
+		if num == methodRulePermission && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return
+			}
+			b = b[n:]
+			r.permission = v
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return
+		}
+		b = b[n:]
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// (wms.api.method) = { middleware: "audit" anonymous: true permission: "bin.read" },
+// permission 单独成段, 与 protoc 合并重复选项时的输出一致
+func TestGetMethodRule(t *testing.T) {
+	var r []byte
+	r = protowire.AppendTag(r, methodRuleMiddleware, protowire.BytesType)
+	r = protowire.AppendString(r, "audit")
+	r = protowire.AppendTag(r, methodRuleAnonymous, protowire.VarintType)
+	r = protowire.AppendVarint(r, protowire.EncodeBool(true))
+	// 未知字段跳过
+	r = protowire.AppendTag(r, 99, protowire.VarintType)
+	r = protowire.AppendVarint(r, 1)
+
+	var p []byte
+	p = protowire.AppendTag(p, methodRulePermission, protowire.BytesType)
+	p = protowire.AppendString(p, "bin.read")
+
+	var b []byte
+	b = protowire.AppendTag(b, methodRuleField, protowire.BytesType)
+	b = protowire.AppendBytes(b, r)
+	b = protowire.AppendTag(b, methodRuleField, protowire.BytesType)
+	b = protowire.AppendBytes(b, p)
+
+	gen := newPlugin(t, binFile(b))
+	methods := gen.Files[len(gen.Files)-1].Services[0].Methods
+
+	rule := getMethodRule(methods[0])
+	require.Equal(t, []string{"audit"}, rule.middleware)
+	require.True(t, rule.anonymous)
+	require.Equal(t, "bin.read", rule.permission)
+
+	require.Equal(t, methodRule{}, getMethodRule(methods[1]))
+}
@@ -57,8 +57,8 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 
 	// registration.
 	serviceDescVar := service.GoName + "Router" + "_Desc"
-	g.P("func Register", service.GoName, "Router(s ", runtimePackage.Ident("RouterRegistrar"), ", srv ", serverType, ") {")
-	g.P("s.RegisterRouter(&", serviceDescVar, `, srv)`)
+	g.P("func Register", service.GoName, "Router(s ", runtimePackage.Ident("RouterRegistrar"), ", srv ", serverType, ", opts ...", runtimePackage.Ident("RouterOption"), ") {")
+	g.P("s.RegisterRouter(&", serviceDescVar, `, srv, opts...)`)
 	g.P("}")
 	g.P()
 
@@ -74,45 +74,212 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 	g.P("ServiceName: ", strconv.Quote(string(service.Desc.FullName())), ",")
 	g.P("Methods: []", runtimePackage.Ident("MethodDesc"), "{")
 	for i, method := range service.Methods {
-		meth, path := getHttpRule(method)
+		rule := getHttpRule(method)
+		if err := checkStreaming(method, rule); err != nil {
+			gen.Error(fmt.Errorf("%s: %w", method.Desc.FullName(), err))
+		}
+		if err := checkPathTemplate(method, rule.path); err != nil {
+			gen.Error(fmt.Errorf("%s: %w", method.Desc.FullName(), err))
+		}
+		if err := checkBody(method, rule.body); err != nil {
+			gen.Error(fmt.Errorf("%s: %w", method.Desc.FullName(), err))
+		}
+		if err := checkResponseBody(method, rule.responseBody); err != nil {
+			gen.Error(fmt.Errorf("%s: %w", method.Desc.FullName(), err))
+		}
 		g.P("{")
 		g.P("Name: ", strconv.Quote(string(method.Desc.Name())), ",")
-		g.P("Method: ", strconv.Quote(meth), ",")
-		g.P("Path: ", strconv.Quote(path), ",")
-		g.P("Handler: ", handlerNames[i], ",")
+		g.P("Method: ", strconv.Quote(rule.method), ",")
+		g.P("Path: ", strconv.Quote(rule.path), ",")
+		if rule.body != "" {
+			g.P("Body: ", strconv.Quote(rule.body), ",")
+		}
+		if rule.responseBody != "" {
+			g.P("ResponseBody: ", strconv.Quote(rule.responseBody), ",")
+		}
+		mrule := getMethodRule(method)
+		if len(mrule.middleware) > 0 {
+			quoted := make([]string, 0, len(mrule.middleware))
+			for _, m := range mrule.middleware {
+				quoted = append(quoted, strconv.Quote(m))
+			}
+			g.P("Middleware: []string{", strings.Join(quoted, ", "), "},")
+		}
+		if mrule.anonymous {
+			g.P("Anonymous: true,")
+		}
+		if mrule.permission != "" {
+			g.P("Permission: ", strconv.Quote(mrule.permission), ",")
+		}
+		if method.Desc.IsStreamingServer() {
+			g.P("StreamHandler: ", handlerNames[i], ",")
+		} else {
+			g.P("Handler: ", handlerNames[i], ",")
+		}
 		g.P("},")
 	}
 	g.P("},")
+	g.P("Metadata: ", strconv.Quote(file.Desc.Path()), ",")
 	g.P("}")
+	g.P()
+
+	genClient(g, service, serviceDescVar)
+}
+
+// 按 HttpRule 调用的客户端, 方法与 ServerHandler 一致, 另加调用选项.
+// 服务端流方法不生成
+func genClient(g *protogen.GeneratedFile, service *protogen.Service, serviceDescVar string) {
+	clientType := service.GoName + "RouterClient"
+	implType := unexport(clientType)
+
+	g.P("type ", clientType, " interface {")
+	for _, method := range service.Methods {
+		if method.Desc.IsStreamingServer() {
+			continue
+		}
+		g.P(method.Comments.Leading, // 注释
+			clientSignature(g, method))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("type ", implType, " struct {")
+	g.P("cc ", runtimePackage.Ident("ClientConn"))
+	g.P("}")
+	g.P()
+
+	g.P("func New", clientType, "(cc ", runtimePackage.Ident("ClientConn"), ") ", clientType, " {")
+	g.P("return &", implType, "{cc}")
+	g.P("}")
+	g.P()
+
+	for i, method := range service.Methods {
+		if method.Desc.IsStreamingServer() {
+			continue
+		}
+		g.P("func (c *", implType, ") ", clientSignature(g, method), " {")
+		g.P("out := new(", method.Output.GoIdent, ")")
+		g.P("if err := c.cc.Invoke(ctx, &", serviceDescVar, ".Methods[", i, "], in, out, opts...); err != nil { return nil, err }")
+		g.P("return out, nil")
+		g.P("}")
+		g.P()
+	}
+}
+
+func clientSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
+	return method.GoName + "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
+		", in *" + g.QualifiedGoIdent(method.Input.GoIdent) +
+		", opts ..." + g.QualifiedGoIdent(runtimePackage.Ident("CallOption")) +
+		") (*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+}
+
+func unexport(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
 }
 
 func serverSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
 	var reqArgs []string
 	ret := "error"
 
+	if method.Desc.IsStreamingServer() {
+		reqArgs = append(reqArgs, "*"+g.QualifiedGoIdent(method.Input.GoIdent))
+		reqArgs = append(reqArgs, streamType(method))
+		return method.GoName + "(" + strings.Join(reqArgs, ", ") + ") " + ret
+	}
+
 	reqArgs = append(reqArgs, g.QualifiedGoIdent(contextPackage.Ident("Context")))
 	reqArgs = append(reqArgs, "*"+g.QualifiedGoIdent(method.Input.GoIdent))
 	ret = "(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
 	return method.GoName + "(" + strings.Join(reqArgs, ", ") + ") " + ret
 }
 
+// 服务端流的发送接口, 如 BinService_WatchBinRouterStream, 不与 protoc-gen-go-grpc 的 BinService_WatchBinServer 冲突
+func streamType(method *protogen.Method) string {
+	return method.Parent.GoName + "_" + method.GoName + "RouterStream"
+}
+
+// 只支持服务端流, 请求仍只有一条
+func checkStreaming(method *protogen.Method, rule httpRule) error {
+	switch {
+	case method.Desc.IsStreamingClient():
+		return fmt.Errorf("client streaming is not supported")
+	case method.Desc.IsStreamingServer() && rule.responseBody != "":
+		return fmt.Errorf("response_body is not supported for streaming methods")
+	}
+
+	return nil
+}
+
 func genServerMethod(gen *protogen.Plugin, file *protogen.File, g *protogen.GeneratedFile, method *protogen.Method) string {
 	service := method.Parent
 	hname := fmt.Sprintf("_%sRouter_%s_Handler", service.GoName, method.GoName)
 
-	g.P("func ", hname, "(srv interface{}, ctx ", contextPackage.Ident("Context"), ", dec func(interface{}) error) (interface{}, error) {")
+	if method.Desc.IsStreamingServer() {
+		genStreamMethod(g, method, hname)
+		return hname
+	}
+
+	g.P("func ", hname, "(srv interface{}, ctx ", contextPackage.Ident("Context"), ", dec func(interface{}) error, invoke ", runtimePackage.Ident("UnaryInvoker"), ") (interface{}, error) {")
 	g.P("in := new(", method.Input.GoIdent, ")")
 	g.P("if err := dec(in); err != nil { return nil, err }")
-	g.P("return srv.(", service.GoName, "ServerHandler).", method.GoName, "(ctx, in)")
+	g.P("handler := func(ctx ", contextPackage.Ident("Context"), ", req interface{}) (interface{}, error) {")
+	g.P("if err := ", runtimePackage.Ident("Validate"), "(ctx, req); err != nil { return nil, err }")
+	g.P("return srv.(", service.GoName, "ServerHandler).", method.GoName, "(ctx, req.(*", method.Input.GoIdent, "))")
+	g.P("}")
+	g.P("if invoke == nil { return handler(ctx, in) }")
+	g.P("return invoke(ctx, in, handler)")
 	g.P("}")
 	g.P()
 
 	return hname
 }
 
-func getHttpRule(method *protogen.Method) (string, string) {
+func genStreamMethod(g *protogen.GeneratedFile, method *protogen.Method, hname string) {
+	service := method.Parent
+	streamType := streamType(method)
+	implType := unexport(service.GoName) + method.GoName + "RouterStream"
+
+	g.P("type ", streamType, " interface {")
+	g.P("Send(*", method.Output.GoIdent, ") error")
+	g.P(runtimePackage.Ident("ServerStream"))
+	g.P("}")
+	g.P()
+
+	g.P("type ", implType, " struct {")
+	g.P(runtimePackage.Ident("ServerStream"))
+	g.P("}")
+	g.P()
+
+	g.P("func (x *", implType, ") Send(m *", method.Output.GoIdent, ") error {")
+	g.P("return x.ServerStream.SendMsg(m)")
+	g.P("}")
+	g.P()
+
+	// 拦截器包住整个流, 修改后的 context 通过 StreamWithContext 传给方法
+	g.P("func ", hname, "(srv interface{}, stream ", runtimePackage.Ident("ServerStream"), ", dec func(interface{}) error, invoke ", runtimePackage.Ident("UnaryInvoker"), ") error {")
+	g.P("in := new(", method.Input.GoIdent, ")")
+	g.P("if err := dec(in); err != nil { return err }")
+	g.P("handler := func(ctx ", contextPackage.Ident("Context"), ", req interface{}) (interface{}, error) {")
+	g.P("if err := ", runtimePackage.Ident("Validate"), "(ctx, req); err != nil { return nil, err }")
+	g.P("return nil, srv.(", service.GoName, "ServerHandler).", method.GoName, "(req.(*", method.Input.GoIdent, "), &", implType, "{", runtimePackage.Ident("StreamWithContext"), "(ctx, stream)})")
+	g.P("}")
+	g.P("if invoke == nil { _, err := handler(stream.Context(), in); return err }")
+	g.P("_, err := invoke(stream.Context(), in, handler)")
+	g.P("return err")
+	g.P("}")
+	g.P()
+}
+
+type httpRule struct {
+	method       string
+	path         string
+	body         string
+	responseBody string
+}
+
+func getHttpRule(method *protogen.Method) httpRule {
 	if method.Desc.Options() == nil || !proto.HasExtension(method.Desc.Options(), annotations.E_Http) {
-		return "", ""
+		return httpRule{}
 	}
 
 	// http rules
@@ -140,8 +307,13 @@ func getHttpRule(method *protogen.Method) (string, string) {
 	}
 
 	if len(meth) == 0 || len(path) == 0 {
-		return "", ""
+		return httpRule{}
 	}
 
-	return meth, path
+	return httpRule{
+		method:       meth,
+		path:         path,
+		body:         rule.GetBody(),
+		responseBody: rule.GetResponseBody(),
+	}
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+)
+
+// 与 protoc-gen-go-grpc 生成的顶层名称相同的声明, 只用于检查同一个包中没有重名
+func genGrpcNames(g *protogen.GeneratedFile, file *protogen.File) {
+	g.P("package ", file.GoPackageName)
+	for _, s := range file.Services {
+		name, lower := s.GoName, unexport(s.GoName)
+		g.P("type ", name, "Client interface{}")
+		g.P("type ", lower, "Client struct{}")
+		g.P("func New", name, "Client() ", name, "Client { return nil }")
+		g.P("type ", name, "Server interface{}")
+		g.P("type Unimplemented", name, "Server struct{}")
+		g.P("type Unsafe", name, "Server interface{}")
+		g.P("func Register", name, "Server() {}")
+		g.P("var ", name, "_ServiceDesc = 0")
+		for _, m := range s.Methods {
+			g.P("func _", name, "_", m.GoName, "_Handler() {}")
+			if m.Desc.IsStreamingServer() {
+				g.P("type ", name, "_", m.GoName, "Client interface{}")
+				g.P("type ", lower, m.GoName, "Client struct{}")
+				g.P("type ", name, "_", m.GoName, "Server interface{}")
+				g.P("type ", lower, m.GoName, "Server struct{}")
+			}
+		}
+	}
+}
+
+// 生成的 router 与 .pb.go 和 gRPC 的代码在同一个包中编译
+func TestRouterCompiles(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found")
+	}
+	root, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	file := binFile(nil)
+	watch := method("WatchBin", ".wms.v1.GetBinRequest", ".wms.v1.Bin",
+		&annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/bins/{bin_id}:watch"}}, nil)
+	watch.ServerStreaming = proto.Bool(true)
+	file.Service[0].Method = append(file.Service[0].Method, watch)
+
+	gen := newPlugin(t, file)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		gengo.GenerateFile(gen, f)
+		require.NotNil(t, generateFile(gen, f))
+		genGrpcNames(gen.NewGeneratedFile(f.GeneratedFilenamePrefix+"_grpc.pb.go", f.GoImportPath), f)
+	}
+	resp := gen.Response()
+	require.Empty(t, resp.GetError())
+
+	dir := t.TempDir()
+	for _, f := range resp.GetFile() {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, filepath.Base(f.GetName())), []byte(f.GetContent()), 0644))
+	}
+
+	// 使用仓库的 runtime 和已下载的依赖
+	gomod := fmt.Sprintf("module example.com/wms\n\ngo 1.16\n\nrequire github.com/devil-dwj/go-wms v0.0.0\n\nreplace github.com/devil-dwj/go-wms => %s\n", root)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.sum"), mustRead(t, filepath.Join(root, "go.sum")), 0644))
+
+	cmd := exec.Command(goTool, "build", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, strings.TrimSpace(string(out)))
+}
+
+func mustRead(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+
+	return data
+}
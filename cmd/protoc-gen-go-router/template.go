@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// 路径模板中的 {field} / {field=sub/*} 变量
+func templateFieldPaths(path string) ([]string, error) {
+	var paths []string
+	rest := path
+	for {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("unclosed variable in %q", path)
+		}
+
+		v := rest[i+1 : i+j]
+		if k := strings.IndexByte(v, '='); k >= 0 {
+			v = v[:k]
+		}
+		paths = append(paths, v)
+		rest = rest[i+j+1:]
+	}
+
+	return paths, nil
+}
+
+// 校验路径变量对应请求消息中的非 repeated 标量字段
+func checkPathTemplate(method *protogen.Method, path string) error {
+	paths, err := templateFieldPaths(path)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		msg := method.Input
+		names := strings.Split(p, ".")
+		for i, name := range names {
+			var field *protogen.Field
+			for _, f := range msg.Fields {
+				if string(f.Desc.Name()) == name {
+					field = f
+					break
+				}
+			}
+			if field == nil {
+				return fmt.Errorf("path variable {%s}: no field %q in %s", p, name, msg.Desc.FullName())
+			}
+			if field.Desc.IsList() || field.Desc.IsMap() {
+				return fmt.Errorf("path variable {%s}: field %q is repeated", p, name)
+			}
+
+			if i < len(names)-1 {
+				if field.Message == nil {
+					return fmt.Errorf("path variable {%s}: field %q is not a message", p, name)
+				}
+				msg = field.Message
+			} else if field.Message != nil {
+				return fmt.Errorf("path variable {%s}: field %q is a message", p, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 校验 body 为 "*" 或请求消息中的非 repeated 消息字段
+func checkBody(method *protogen.Method, body string) error {
+	if body == "" || body == "*" {
+		return nil
+	}
+
+	for _, f := range method.Input.Fields {
+		if string(f.Desc.Name()) != body {
+			continue
+		}
+		if f.Message == nil || f.Desc.IsList() || f.Desc.IsMap() {
+			return fmt.Errorf("body %q: field is not a message", body)
+		}
+
+		paths, err := templateFieldPaths(getHttpRule(method).path)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			if p == body || strings.HasPrefix(p, body+".") {
+				return fmt.Errorf("body %q: field is bound by path variable {%s}", body, p)
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("body %q: no field in %s", body, method.Input.Desc.FullName())
+}
+
+// 校验 response_body 为回复消息中的字段
+func checkResponseBody(method *protogen.Method, responseBody string) error {
+	if responseBody == "" {
+		return nil
+	}
+
+	for _, f := range method.Output.Fields {
+		if string(f.Desc.Name()) == responseBody {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("response_body %q: no field in %s", responseBody, method.Output.Desc.FullName())
+}